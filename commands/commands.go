@@ -15,6 +15,12 @@ var (
 	}
 )
 
+func init() {
+	cobra.OnInitialize(loadSigningKey, loadConfig)
+	RootCmd.PersistentFlags().StringVar(&workTreeOption, "work-tree", "", "Root directory of files of the self repository (default: $ARCIV_WORK_TREE)")
+	RootCmd.PersistentFlags().StringVar(&arcivDirOption, "arciv-dir", "", "Metadata directory of the self repository instead of .arciv (default: $ARCIV_DIR)")
+}
+
 func Run() {
 	RootCmd.Execute()
 }
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkCmd = &cobra.Command{
+		Use:   "check",
+		Run:   checkCommand,
+		Short: "Verify signatures of a timeline and commits",
+		Long: `Verify signatures of a timeline and all commits (of the self repository by default).
+The command also verifies that tags of each commit match the hash in its commit id.`,
+		Args: cobra.NoArgs,
+	}
+)
+
+func checkCommand(cmd *cobra.Command, args []string) {
+	if err := checkAction(); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name")
+	checkCmd.Flags().BoolVarP(&requireSignatureOption, "require-signature", "S", false, "Treat unsigned history as an error")
+}
+
+func checkAction() (err error) {
+	var repo Repository
+	if repositoryNameOption == "" {
+		repo = SelfRepo()
+	} else {
+		repo, err = findRepo(repositoryNameOption)
+		if err != nil {
+			return err
+		}
+	}
+
+	problems := 0
+	status, err := repo.VerifyTimeline()
+	if err != nil {
+		return err
+	}
+	messageStdin("timeline: " + status.String())
+	if isProblem(status) {
+		problems++
+	}
+
+	timeline, err := repo.LoadTimeline()
+	if err != nil {
+		return err
+	}
+	for _, cId := range timeline {
+		status, err := repo.VerifyCommit(cId)
+		if err != nil {
+			return err
+		}
+		commit, err := repo.LoadCommit(cId)
+		if err != nil {
+			return err
+		}
+		if commit.verifyHash() != nil {
+			messageStdin(cId + ": hash mismatch")
+			problems++
+			continue
+		}
+		messageStdin(cId + ": " + status.String())
+		if isProblem(status) {
+			problems++
+		}
+	}
+	if problems > 0 {
+		return errors.New("Verification failed")
+	}
+	return nil
+}
+
+func isProblem(status SignatureStatus) bool {
+	return status == SIGNATURE_INVALID || status == SIGNATURE_UNTRUSTED || status == SIGNATURE_UNSIGNED && requireSignatureOption
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	commitCmd = &cobra.Command{
+		Use:   "commit",
+		Run:   commitCommand,
+		Short: "Record the current files of the self repository as a commit",
+		Long: `Record the current files of the self repository as a commit without sending them to another repository.
+The commits can be sent later with 'arciv store --commits'.
+Example:
+        arciv commit --message "photos of the trip"
+          ... record the current files with the message
+        arciv commit --merge nas:latest
+          ... record the current files as a merge of the latest commit of the repository 'nas'`,
+		Args: cobra.NoArgs,
+	}
+)
+
+var commitMessageOption string
+var commitMergeOption []string
+
+func commitCommand(cmd *cobra.Command, args []string) {
+	if err := commitAction(); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(commitCmd)
+	commitCmd.Flags().StringVarP(&commitMessageOption, "message", "m", "", "Message of the commit")
+	commitCmd.Flags().StringArrayVar(&commitMergeOption, "merge", []string{}, "Record the commit (revision) as a merged parent, after merging files of it by hand")
+	commitCmd.Flags().BoolVarP(&runFastlyOption, "fast", "s", false, "Check fastly with checking timestamp, without checking file hash")
+	commitCmd.Flags().BoolVarP(&debugOption, "debug", "b", false, "Debug print")
+	commitCmd.Flags().BoolVarP(&oneFileSystemOption, "one-file-system", "x", false, "Do not cross mount points")
+}
+
+func commitAction() error {
+	commit, err := createCommitStructure()
+	if err != nil {
+		return err
+	}
+	var merged []string
+	for _, revision := range commitMergeOption {
+		_, mergedCommit, err := loadRevision(SelfRepo(), revision)
+		if err != nil {
+			return err
+		}
+		merged = append(merged, mergedCommit.Id)
+	}
+	commit, err = describeCommit(commit, merged...)
+	if err != nil {
+		return err
+	}
+	commit.Message = commitMessageOption
+	written, err := SelfRepo().AddCommit(commit)
+	if err != nil {
+		return err
+	}
+	if written {
+		message("created commit '" + commit.Id + "'")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Use:   "config [<key> [<value>]]",
+		Run:   configCommand,
+		Short: "Show or set configurations of the self repository",
+		Long: `Show or set configurations of the self repository.
+On excute 'arciv config', the command shows all configurations.
+On excute 'arciv config <key>', the command shows the configuration.
+On excute 'arciv config <key> <value>', the command sets the configuration to .arciv/config.
+
+Example:
+        arciv config hash-algorithm blake3
+          ... hash files and commits with BLAKE3 from the next commit
+`,
+		Args: cobra.MaximumNArgs(2),
+	}
+)
+
+func configCommand(cmd *cobra.Command, args []string) {
+	if err := configAction(args); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+}
+
+func configAction(args []string) error {
+	if len(args) == 0 {
+		for _, key := range configKeys {
+			messageStdin(key.Name + ":" + configValue(key.Name) + " (" + key.Description + ")")
+		}
+		return nil
+	}
+	key, err := findConfigKey(args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		messageStdin(configValue(key.Name))
+		return nil
+	}
+	return setConfigValue(key.Name, args[1])
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"errors"
 	"github.com/spf13/cobra"
+	"strconv"
 )
 
 var (
@@ -15,7 +16,8 @@ The command prints changes from the first argument's commit to the second one
 Arguments need commit-id, allow a part of commit-id.
 For example, if commit-id is '6038d4c5-92e040fe51a920f869a929e3a309e072c7bfe115a1c57b0b472e248f3f09570d',
 arguments allow '6038d4', '6038d4c5-92e', '92e', '92e040fe' and so on...
-If a part of commit-id points more than 1 commit, an error occurs. `,
+If a part of commit-id points more than 1 commit, an error occurs.
+Arguments also allow revisions like 'latest~3', '@{2023-04-01}', a label and 'repo:alias' of another repository. `,
 		Args: cobra.ExactArgs(2),
 	}
 )
@@ -35,18 +37,18 @@ func init() {
 
 func diffAction(commitAlias0, commitAlias1 string) (err error) {
 	selfRepo := SelfRepo()
-	commit0, err := selfRepo.LoadCommitFromAlias(commitAlias0)
+	_, commit0, err := loadRevision(selfRepo, commitAlias0)
 	if err != nil {
 		return err
 	}
-	commit1, err := selfRepo.LoadCommitFromAlias(commitAlias1)
+	_, commit1, err := loadRevision(selfRepo, commitAlias1)
 	if err != nil {
 		return err
 	}
 	if commit0.Id == commit1.Id {
 		return errors.New("Same commit")
 	}
-	printDiffs(diffTags(commit0.Tags, commit1.Tags))
+	printDiffs(diffTags(normalizeTags(commit0.Tags), normalizeTags(commit1.Tags)))
 	return nil
 }
 
@@ -54,7 +56,13 @@ func diffTags(tagsBefore, tagsAfter []Tag) (deleted []Tag, added []Tag) {
 	ib, ia := 0, 0
 	for ib < len(tagsBefore) && ia < len(tagsAfter) {
 		compared := compareTag(tagsBefore[ib], tagsAfter[ia])
-		if compared == 0 {
+		if compared == 0 && !sameEntry(tagsBefore[ib], tagsAfter[ia]) {
+			// only metadata, the type or the target is changed
+			deleted = append(deleted, tagsBefore[ib])
+			added = append(added, tagsAfter[ia])
+			ib++
+			ia++
+		} else if compared == 0 {
 			ib++
 			ia++
 		} else if compared < 0 {
@@ -75,6 +83,8 @@ func diffTags(tagsBefore, tagsAfter []Tag) (deleted []Tag, added []Tag) {
 }
 
 func printDiffs(deleted, added []Tag) {
+	deletedSize, deletedSizeKnown := sizeOfTags(deleted, false)
+	addedSize, addedSizeKnown := sizeOfTags(added, false)
 	if simplyPrinting {
 		for _, c := range deleted {
 			messageStdin("\x1b[31m" + "- " + c.String() + "\x1b[0m")
@@ -89,7 +99,7 @@ func printDiffs(deleted, added []Tag) {
 		// same hash
 		idx := findTagIndex(added, dc, FIND_HASH|FIND_PATH)
 		if idx != -1 {
-			messageStdin("update: " + dc.Path + ", hash: " + dc.Hash.String() + ", timestamp: \x1b[31m" + timestamp2string(dc.Timestamp) + "\x1b[0m -> \x1b[32m" + timestamp2string(added[idx].Timestamp) + "\x1b[0m")
+			messageStdin("update: " + dc.Path + ", hash: " + dc.Hash.String() + ", timestamp: \x1b[31m" + timestamp2string(dc.Timestamp) + "\x1b[0m -> \x1b[32m" + timestamp2string(added[idx].Timestamp) + "\x1b[0m" + metadataChangeString(dc, added[idx]) + xattrsChangeString(dc, added[idx]) + entryString(added[idx]))
 			added = append(added[:idx], added[idx+1:]...)
 			continue
 		}
@@ -102,15 +112,80 @@ func printDiffs(deleted, added []Tag) {
 		// same path, but not same hash
 		idx = findTagIndex(added, dc, FIND_PATH)
 		if idx != -1 {
-			messageStdin("rewrite: " + dc.Path + ", hash: \x1b[31m" + dc.Hash.String() + "\x1b[0m -> \x1b[32m" + added[idx].Hash.String() + "\x1b[0m")
+			messageStdin("rewrite: " + dc.Path + ", hash: \x1b[31m" + dc.Hash.String() + "\x1b[0m -> \x1b[32m" + added[idx].Hash.String() + "\x1b[0m" + sizeChangeString(dc, added[idx]))
 			added = append(added[:idx], added[idx+1:]...)
 			continue
 		}
 		// similar tag is not found
-		messageStdin("\x1b[31mdeleted: " + dc.Path + ", hash: " + dc.Hash.String() + "\x1b[0m")
+		messageStdin("\x1b[31mdeleted: " + dc.Path + ", hash: " + dc.Hash.String() + sizeString(dc) + entryString(dc) + "\x1b[0m")
 	}
 	// similar tag is not found
 	for _, ac := range added {
-		messageStdin("\x1b[32madded: " + ac.Path + ", hash: " + ac.Hash.String() + "\x1b[0m")
+		messageStdin("\x1b[32madded: " + ac.Path + ", hash: " + ac.Hash.String() + sizeString(ac) + entryString(ac) + "\x1b[0m")
+	}
+	if deletedSizeKnown && addedSizeKnown && len(deleted)+len(added) > 0 {
+		message("size: -" + size2string(deletedSize) + ", +" + size2string(addedSize))
+	}
+}
+
+func sameMetadata(t0, t1 Tag) bool {
+	return t0.UsedMetadata == t1.UsedMetadata && t0.Metadata == t1.Metadata
+}
+
+func sameEntry(t0, t1 Tag) bool {
+	return sameMetadata(t0, t1) && sameXattrs(t0, t1) && t0.entryType() == t1.entryType() && t0.Target == t1.Target
+}
+
+func xattrsChangeString(before, after Tag) string {
+	if sameXattrs(before, after) {
+		return ""
+	}
+	return ", xattrs: \x1b[31m" + strconv.Itoa(len(before.Xattrs)) + "\x1b[0m -> \x1b[32m" + strconv.Itoa(len(after.Xattrs)) + "\x1b[0m"
+}
+
+// entryString shows the type of a tag except a regular file
+func entryString(tag Tag) string {
+	switch tag.entryType() {
+	case TAG_TYPE_SYMLINK:
+		return ", symlink -> " + tag.Target
+	case TAG_TYPE_HARDLINK:
+		return ", hardlink = " + tag.Target
+	case TAG_TYPE_DIR:
+		return ", empty directory"
+	case TAG_TYPE_FIFO, TAG_TYPE_SOCKET:
+		return ", " + tag.entryType()
+	case TAG_TYPE_CHARDEV, TAG_TYPE_BLOCKDEV:
+		return ", " + tag.entryType() + " " + tag.Target
+	case TAG_TYPE_NESTED:
+		return ", nested repository at " + name2string(tag.Target)
+	}
+	return ""
+}
+
+func metadataChangeString(before, after Tag) string {
+	if sameMetadata(before, after) {
+		return ""
+	}
+	b, a := "(none)", "(none)"
+	if before.UsedMetadata {
+		b = before.Line(TAG_FIELDS_METADATA)
+	}
+	if after.UsedMetadata {
+		a = after.Line(TAG_FIELDS_METADATA)
+	}
+	return ", metadata: \x1b[31m" + b + "\x1b[0m -> \x1b[32m" + a + "\x1b[0m"
+}
+
+func sizeString(tag Tag) string {
+	if !tag.UsedSize {
+		return ""
+	}
+	return ", size: " + size2string(tag.Size)
+}
+
+func sizeChangeString(before, after Tag) string {
+	if !before.UsedSize || !after.UsedSize {
+		return sizeString(after)
 	}
+	return ", size: \x1b[31m" + size2string(before.Size) + "\x1b[0m -> \x1b[32m" + size2string(after.Size) + "\x1b[0m"
 }
@@ -50,5 +50,17 @@ func TestDiffTags(t *testing.T) {
 			added[1].String() != "22222222222222222222222222222222ffffffffffffffffffffffffffffffff 2222/2222" {
 			t.Errorf("diffTags() return a added slice %s", added)
 		}
+
+		// only metadata is changed
+		tagsBefore = []Tag{
+			Tag{Path: "0000/0000", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"), Metadata: FileMetadata{Mode: 0644}, UsedMetadata: true},
+		}
+		tagsAfter = []Tag{
+			Tag{Path: "0000/0000", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"), Metadata: FileMetadata{Mode: 0755}, UsedMetadata: true},
+		}
+		deleted, added = diffTags(tagsBefore, tagsAfter)
+		if len(deleted) != 1 || len(added) != 1 || added[0].Metadata.Mode != 0755 {
+			t.Errorf("diffTags() return (%s, %s), want the changed tag in both", deleted, added)
+		}
 	})
 }
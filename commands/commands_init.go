@@ -11,7 +11,9 @@ var (
 		Run:   initCommand,
 		Short: "Initialize a repository",
 		Long: `Initialize a repository.
-The repository's root directory specifies the current directory by generating '.arciv' directory on the current directory.`,
+The repository's root directory specifies the current directory by generating '.arciv' directory on the current directory.
+With --arciv-dir (or $ARCIV_DIR), the metadata directory is generated there instead of '.arciv',
+and the root directory is --work-tree (or $ARCIV_WORK_TREE) or the current directory.`,
 		Args: cobra.NoArgs,
 	}
 )
@@ -22,12 +24,35 @@ func initCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+var hashAlgorithmOption string
+
 func initAction() error {
-	currentDir, err := os.Getwd()
+	root, err := workTreeSetting()
 	if err != nil {
 		return err
 	}
-	return Repository{Name: "self", Location: RepositoryLocationFile{Path: currentDir}}.Init()
+	if root == "" {
+		root, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+	dir, err := arcivDirSetting()
+	if err != nil {
+		return err
+	}
+	if hashAlgorithmOption != "" {
+		if _, err := findHashAlgorithm(hashAlgorithmOption); err != nil {
+			return err
+		}
+	}
+	repo := Repository{Name: "self", Location: RepositoryLocationFile{Path: root, ArcivDir: dir}}
+	err = repo.Init()
+	if err != nil || hashAlgorithmOption == "" {
+		return err
+	}
+	// written to the initialized repository, because the self repository may be a repository which the directory is in
+	return repo.Location.writeLines(".arciv/config", config2strs(map[string]string{"hash-algorithm": hashAlgorithmOption}))
 }
 
 func (r Repository) Init() error {
@@ -35,7 +60,7 @@ func (r Repository) Init() error {
 	switch lf := r.Location.(type) {
 	case RepositoryLocationFile:
 		for _, dir := range createDirsInDotArciv {
-			err := fileOp.mkdirAll(lf.Path + "/.arciv/" + dir)
+			err := fileOp.mkdirAll(lf.path(".arciv/" + dir))
 			if err != nil {
 				return err
 			}
@@ -60,4 +85,5 @@ func (r Repository) Init() error {
 }
 func init() {
 	RootCmd.AddCommand(initCmd)
+	initCmd.Flags().StringVarP(&hashAlgorithmOption, "hash-algorithm", "a", "", "Hash algorithm for commits and blobs (sha256, sha512 or blake3)")
 }
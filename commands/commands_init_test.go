@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -161,4 +163,41 @@ func TestRepositoryInit(t *testing.T) {
 			t.Errorf("Repository.Init() does not create '.arciv/timestamps'")
 		}
 	})
+
+	// func initAction() error
+	t.Run("initAction() in a directory of a repository", func(t *testing.T) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			// the repository which the current directory is in
+			rootDir: func() string {
+				return "/parent"
+			},
+			mkdirAll: func(path string) error {
+				return nil
+			},
+			findFilePaths: func(root string) ([]string, error) {
+				return []string{}, nil
+			},
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+		}
+		hashAlgorithmOption = "blake3"
+		defer func() { hashAlgorithmOption = "" }()
+		err = initAction()
+		if err != nil {
+			t.Errorf("initAction() return a error \"%s\"", err)
+		}
+		if got := strings.Join(files[cwd+"/.arciv/config"], ","); got != "hash-algorithm:blake3" {
+			t.Errorf("initAction() writes %q to .arciv/config of the new repository, want \"hash-algorithm:blake3\"", got)
+		}
+		if _, ok := files["/parent/.arciv/config"]; ok {
+			t.Errorf("initAction() writes .arciv/config of the parent repository")
+		}
+	})
 }
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	keyCmd = &cobra.Command{
+		Use:   "key ( | generate | trust <repository> <public-key> | untrust <repository> <public-key>)",
+		Run:   keyCommand,
+		Short: "Show, generate or trust ed25519 keys to sign commits and timelines",
+		Long: `Show, generate or trust ed25519 keys to sign commits and timelines.
+On excute 'arciv key', the command shows the public key of the self repository and trusted public keys.
+On excute 'arciv key generate', the command generates a signing key to .arciv/signing-key.
+After generating, commits and timelines written by arciv are signed with the key.
+On excute 'arciv key trust', the command registers a public key trusted to sign history of the repository.
+On excute 'arciv key untrust', the command removes a trusted public key.
+
+Example:
+        arciv key generate
+          ... generate a signing key
+        arciv key trust aws-s3-repo 3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29
+          ... trust the public key for history stored on the repository 'aws-s3-repo'
+`,
+	}
+)
+
+var forceGeneratingKeyOption bool
+
+func keyCommand(cmd *cobra.Command, args []string) {
+	if err := keyAction(args); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(keyCmd)
+	keyCmd.Flags().BoolVarP(&forceGeneratingKeyOption, "force", "f", false, "Overwrite the signing key on generating")
+}
+
+func keyAction(args []string) (err error) {
+	if len(args) == 0 {
+		return keyActionShow()
+	}
+	if len(args) == 1 && args[0] == "generate" {
+		return keyActionGenerate()
+	}
+	if len(args) == 3 && args[0] == "trust" {
+		return keyActionTrust(args[1], args[2])
+	}
+	if len(args) == 3 && args[0] == "untrust" {
+		return keyActionUntrust(args[1], args[2])
+	}
+	message("Usage: arciv key")
+	message("       arciv key generate")
+	message("       arciv key trust [repository name] [public key]")
+	message("       arciv key untrust [repository name] [public key]")
+	return nil
+}
+
+func keyActionShow() error {
+	if signingKey == nil {
+		message("The signing key is not generated. Excute 'arciv key generate'")
+	} else {
+		messageStdin("public-key: " + publicKey2string(signingKey.Public().(ed25519.PublicKey)))
+	}
+	lines, err := loadTrustedKeyLines()
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		messageStdin("trusted: " + line)
+	}
+	return nil
+}
+
+func keyActionGenerate() error {
+	path := arcivDir() + "/signing-key"
+	exist, err := fileOp.isExist(path)
+	if err != nil {
+		return err
+	}
+	if exist && !forceGeneratingKeyOption {
+		return errors.New("The signing key already exists")
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	err = fileOp.writeLines(path, signingKey2strs(key))
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		return err
+	}
+	messageStdin("public-key: " + publicKey2string(key.Public().(ed25519.PublicKey)))
+	return nil
+}
+
+func keyActionTrust(repoName string, publicKey string) error {
+	_, err := findRepo(repoName)
+	if err != nil {
+		return err
+	}
+	_, err = str2publicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	lines, err := loadTrustedKeyLines()
+	if err != nil {
+		return err
+	}
+	if isIncluded(lines, repoName+" "+publicKey) {
+		return errors.New("The public key is already trusted")
+	}
+	return writeTrustedKeyLines(append(lines, repoName+" "+publicKey))
+}
+
+func keyActionUntrust(repoName string, publicKey string) error {
+	lines, err := loadTrustedKeyLines()
+	if err != nil {
+		return err
+	}
+	for i, line := range lines {
+		if line != repoName+" "+publicKey {
+			continue
+		}
+		lines = append(lines[:i], lines[i+1:]...)
+		return writeTrustedKeyLines(lines)
+	}
+	return errors.New("The public key is not trusted")
+}
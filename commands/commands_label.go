@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	labelCmd = &cobra.Command{
+		Use:   "label ( list | add <name> <commit-id> | remove <name>)",
+		Run:   labelCommand,
+		Short: "List, add or remove labels of commits",
+		Long: `List, add or remove labels of commits (of the self repository by default).
+A label can be used instead of a commit id, and labels of the self repository are sent to another repository on 'arciv store'.
+
+Example:
+        arciv label add before-reorg a84bfc
+          ... label the commit 'a84bfc' as 'before-reorg'
+        arciv log --commit before-reorg
+          ... print the commit labeled as 'before-reorg'
+        arciv label remove before-reorg
+          ... remove the label 'before-reorg'
+`,
+	}
+)
+
+func labelCommand(cmd *cobra.Command, args []string) {
+	if err := labelAction(args); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(labelCmd)
+	labelCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name")
+}
+
+func labelAction(args []string) (err error) {
+	var repo Repository
+	if repositoryNameOption == "" {
+		repo = SelfRepo()
+	} else {
+		repo, err = findRepo(repositoryNameOption)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(args) == 0 || (len(args) == 1 && args[0] == "list") {
+		return labelActionList(repo)
+	}
+	if len(args) == 3 && args[0] == "add" {
+		return labelActionAdd(repo, args[1], args[2])
+	}
+	if len(args) == 2 && args[0] == "remove" {
+		return labelActionRemove(repo, args[1])
+	}
+	message("Usage: arciv label [list]")
+	message("       arciv label add [label name] [commit id]")
+	message("       arciv label remove [label name]")
+	return nil
+}
+
+func labelActionList(repo Repository) error {
+	labels, err := repo.LoadLabels()
+	if err != nil {
+		return err
+	}
+	for _, label := range labels {
+		messageStdin(label.Line())
+	}
+	return nil
+}
+
+func labelActionAdd(repo Repository, name string, commitAlias string) error {
+	if !isLabelName(name) {
+		return errors.New("The label name '" + name + "' is invalid. It must consist of alphabets, digits, '_', '.' and '-', and must not be like a commit id or '" + REVISION_LATEST + "'")
+	}
+	commit, err := repo.LoadCommitFromAlias(commitAlias)
+	if err != nil {
+		return err
+	}
+	labels, removed, err := repo.loadLabelRecords()
+	if err != nil {
+		return err
+	}
+	if idx := findLabelIndex(labels, name); idx != -1 {
+		message("The label '" + name + "' is moved from '" + labels[idx].CommitId + "'")
+	}
+	err = repo.WriteLabels(setLabel(labels, Label{Name: name, CommitId: commit.Id}), forgetRemovedLabels(removed, name))
+	if err != nil {
+		return err
+	}
+	message("labeled '" + commit.Id + "' as '" + name + "'")
+	return nil
+}
+
+func labelActionRemove(repo Repository, name string) error {
+	labels, removed, err := repo.loadLabelRecords()
+	if err != nil {
+		return err
+	}
+	idx := findLabelIndex(labels, name)
+	if idx == -1 {
+		return errors.New("The label '" + name + "' is not found")
+	}
+	labels, removed = removeLabel(labels, removed, idx)
+	return repo.WriteLabels(labels, removed)
+}
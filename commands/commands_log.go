@@ -2,6 +2,8 @@ package commands
 
 import (
 	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
 )
 
 var (
@@ -16,6 +18,8 @@ var (
 
 var repositoryNameOption string
 var commitAliasOption string
+var verboseOption bool
+var graphOption bool
 
 func logCommand(cmd *cobra.Command, args []string) {
 	if err := logAction(args); err != nil {
@@ -26,7 +30,9 @@ func logCommand(cmd *cobra.Command, args []string) {
 func init() {
 	RootCmd.AddCommand(logCmd)
 	logCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name")
-	logCmd.Flags().StringVarP(&commitAliasOption, "commit", "c", "", "commit id")
+	logCmd.Flags().StringVarP(&commitAliasOption, "commit", "c", "", "commit id or revision")
+	logCmd.Flags().BoolVarP(&verboseOption, "verbose", "v", false, "Print the host, the user, the version, the statistics and the parent of each commit")
+	logCmd.Flags().BoolVarP(&graphOption, "graph", "g", false, "Print the timeline from the latest commit with the graph of parents")
 }
 
 func logAction(args []string) (err error) {
@@ -41,9 +47,18 @@ func logAction(args []string) (err error) {
 	}
 
 	if commitAliasOption == "" {
+		status, err := repo.VerifyTimeline()
+		if err != nil {
+			return err
+		}
+		judgeSignature("The timeline of the repository "+repo.Name, status, false)
 		return printTimeline(repo)
 	}
-	commit, err := repo.LoadCommitFromAlias(commitAliasOption)
+	repo, commit, err := loadRevision(repo, commitAliasOption)
+	if err != nil {
+		return err
+	}
+	err = verifyHistory(repo, commit, false)
 	if err != nil {
 		return err
 	}
@@ -55,15 +70,99 @@ func printTimeline(repo Repository) error {
 	if err != nil {
 		return err
 	}
+	if graphOption {
+		return printGraph(repo, timeline)
+	}
 	for _, cId := range timeline {
-		messageStdin(cId)
+		msg, info, err := repo.loadCommitInfo(cId)
+		if err != nil {
+			return err
+		}
+		if msg == "" {
+			messageStdin(cId)
+		} else {
+			// the first line of the message like 'git log --oneline'
+			messageStdin(cId + " " + strings.SplitN(msg, "\n", 2)[0])
+		}
+		if verboseOption {
+			for _, line := range infoLines(info) {
+				messageStdin("    " + line)
+			}
+		}
+	}
+	return nil
+}
+
+// printGraph prints the timeline from the latest commit with the graph of parents
+func printGraph(repo Repository, timeline []string) error {
+	parentsOf, err := commitParents(repo)
+	if err != nil {
+		return err
+	}
+	var labelErr error
+	lines, err := graphLines(timeline, parentsOf, func(cId string) string {
+		msg, _, err := repo.loadCommitInfo(cId)
+		if err != nil {
+			labelErr = err
+			return cId
+		}
+		if msg == "" {
+			return cId
+		}
+		return cId + " " + strings.SplitN(msg, "\n", 2)[0]
+	})
+	if err != nil {
+		return err
+	}
+	if labelErr != nil {
+		return labelErr
+	}
+	for _, line := range lines {
+		messageStdin(line)
 	}
 	return nil
 }
 
 func printCommit(c Commit) error {
+	if c.Message != "" {
+		message("message: " + c.Message)
+	}
+	for _, line := range infoLines(c.Info) {
+		message(line)
+	}
+	fields := tagFields(c.Tags)
+	if usedSizes(c.Tags) {
+		fields = append([]string{"hash", "size"}, fields[1:]...)
+	}
 	for _, p := range c.Tags {
-		messageStdin(p.String())
+		messageStdin(p.Line(fields))
+	}
+	size, known := sizeOfTags(c.Tags, false)
+	if known {
+		message(strconv.Itoa(len(c.Tags)) + " files, " + size2string(size))
 	}
 	return nil
 }
+
+// infoLines returns lines of CommitInfo. Commits created by older versions do not have it.
+func infoLines(info CommitInfo) (lines []string) {
+	if info.Host != "" {
+		lines = append(lines, "host: "+info.Host)
+	}
+	if info.User != "" {
+		lines = append(lines, "user: "+info.User)
+	}
+	if info.Version != "" {
+		lines = append(lines, "version: "+info.Version)
+	}
+	if info.UsedFiles {
+		lines = append(lines, "files: "+strconv.Itoa(info.Files))
+	}
+	if info.UsedBytes {
+		lines = append(lines, "size: "+size2string(info.Bytes))
+	}
+	for _, parent := range info.Parents {
+		lines = append(lines, "parent: "+parent)
+	}
+	return lines
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rekeyCmd = &cobra.Command{
+		Use:   "rekey",
+		Run:   rekeyCommand,
+		Short: "Add blobs keyed by another hash algorithm to a repository",
+		Long: `Hash blobs stored in a repository (the self repository by default) with another hash algorithm, and add them with the new keys.
+Old blobs are kept, so commits hashed with the old algorithm are still readable.
+After rekeying, new commits hashed with the new algorithm do not upload blobs that the repository already has.
+The repository must be type:file. The hash algorithm is 'hash-algorithm' of 'arciv config' by default.
+
+Example:
+        arciv config hash-algorithm blake3
+        arciv rekey --repository media-stable
+          ... add BLAKE3 keys of blobs in the repository 'media-stable'
+`,
+		Args: cobra.NoArgs,
+	}
+)
+
+func rekeyCommand(cmd *cobra.Command, args []string) {
+	if err := rekeyAction(); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(rekeyCmd)
+	rekeyCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name")
+	rekeyCmd.Flags().StringVarP(&hashAlgorithmOption, "hash-algorithm", "a", "", "Hash algorithm of new keys (sha256, sha512 or blake3)")
+	rekeyCmd.Flags().BoolVarP(&dryRunningOption, "dry-run", "d", false, "Show blobs to rekey")
+}
+
+func rekeyAction() (err error) {
+	repo := SelfRepo()
+	if repositoryNameOption != "" {
+		repo, err = findRepo(repositoryNameOption)
+		if err != nil {
+			return err
+		}
+	}
+	location, ok := repo.Location.(RepositoryLocationFile)
+	if !ok {
+		return errors.New("Rekeying is supported with repositories of type:file only")
+	}
+	algorithm := currentHashAlgorithm()
+	if hashAlgorithmOption != "" {
+		algorithm, err = findHashAlgorithm(hashAlgorithmOption)
+		if err != nil {
+			return err
+		}
+	}
+
+	blobs, err := repo.FetchBlobHashes()
+	if err != nil {
+		return err
+	}
+	base := location.path(".arciv/blob/")
+	for _, blob := range blobs {
+		hash, err := hex2hash(blob)
+		if err != nil {
+			return err
+		}
+		if hash.Algorithm().Name == algorithm.Name {
+			continue
+		}
+		newHash, err := hashFileWith(algorithm, base+blob)
+		if err != nil {
+			return err
+		}
+		if isIncluded(blobs, newHash.String()) {
+			continue
+		}
+		if dryRunningOption {
+			messageStdin("rekey: " + blob + " -> " + newHash.String())
+			continue
+		}
+		err = fileOp.copyFile(base+blob, base+newHash.String())
+		if err != nil {
+			return err
+		}
+		message("rekeyed: " + blob + " -> " + newHash.String())
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replicateCmd = &cobra.Command{
+		Use:   "replicate",
+		Run:   replicateCommand,
+		Short: "Replicate commits and blobs from a repository to another repository directly",
+		Long: `Replicate commits and blobs which a repository does not have from another repository.
+Blobs are streamed from the repository to another repository and verified with their hashes, without a local copy.
+A blob is written with its name only after it is verified, so replicating can be resumed by excuting the command again.
+Example:
+        arciv replicate --from file-disk --to s3-repo
+          ... replicate all commits and blobs of 'file-disk' to 's3-repo'
+        arciv replicate --from file-disk --to s3-repo --commits before-reorg..
+          ... replicate the commit 'before-reorg' and the following commits`,
+		Args: cobra.NoArgs,
+	}
+)
+
+func replicateCommand(cmd *cobra.Command, args []string) {
+	if err := replicateAction(); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(replicateCmd)
+	replicateCmd.Flags().StringVarP(&syncFromOption, "from", "f", "", "repository name to replicate from")
+	replicateCmd.Flags().StringVarP(&syncToOption, "to", "t", "", "repository name to replicate to")
+	replicateCmd.Flags().StringVarP(&commitsOption, "commits", "C", "", "Replicate commits in the range '<from>..<to>' (both included, either can be omitted)")
+	replicateCmd.Flags().BoolVarP(&dryRunningOption, "dry-run", "d", false, "Show commits to replicate without replicating")
+	replicateCmd.Flags().BoolVarP(&requireSignatureOption, "require-signature", "S", false, "Refuse to replicate from unsigned history")
+}
+
+func replicateAction() error {
+	if syncFromOption == "" || syncToOption == "" {
+		return errors.New("Need to specify the repository names to replicate from and to")
+	}
+	if syncFromOption == "self" || syncToOption == "self" {
+		return errors.New("The self repository cannot be replicated. Use 'arciv store' or 'arciv sync' instead")
+	}
+	from, to, err := findRepoPair(syncFromOption, syncToOption)
+	if err != nil {
+		return err
+	}
+	return transferCommits(from, to, commitsOption, replicateBlobs)
+}
@@ -171,7 +171,7 @@ func strs2repository(elements []string) (Repository, error) {
 }
 
 func loadRepos() ([]Repository, error) {
-	lines, err := fileOp.loadLines(fileOp.rootDir() + "/.arciv/repositories")
+	lines, err := fileOp.loadLines(arcivDir() + "/repositories")
 	if err != nil {
 		return []Repository{}, err
 	}
@@ -212,5 +212,5 @@ func writeRepos(repos []Repository) error {
 		}
 		lines = append(lines, repo.String())
 	}
-	return fileOp.writeLines(fileOp.rootDir()+"/.arciv/repositories", lines)
+	return fileOp.writeLines(arcivDir()+"/repositories", lines)
 }
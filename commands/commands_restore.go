@@ -4,31 +4,45 @@ import (
 	"errors"
 	"github.com/spf13/cobra"
 	"strconv"
+	"strings"
 )
 
 var (
 	restoreCmd = &cobra.Command{
-		Use:   "restore",
+		Use:   "restore [-- <path>...]",
 		Run:   restoreCommand,
 		Short: "Restore filles from the specified repository's commit.",
 		Long: `Restore filles from the specified repository's commit with downloading files that don't exist on local.
 Example:
         arciv restore --repository repo-remote --commit a84bfc
           ... restore files immefiately from the commit 'a84bfc' of the repository 'repo-remote'
+        arciv restore --commit repo-remote:@{2023-03-01}
+          ... restore files from the last commit at or before March 1st of the repository 'repo-remote'
+        arciv restore --repository aws-s3-repo --commit a84bfc --wait
+          ... request to restore archived files, wait until they are available, and restore files
+        arciv restore --run-requested 6530a8 --wait
+          ... resume waiting for the restore request after an interruption
+        arciv restore --repository repo-remote --commit a84bfc -- 'photos/2019/**' docs/contract.pdf
+          ... restore only files under photos/2019 and docs/contract.pdf, and leave other files untouched
 `,
-		Args: cobra.NoArgs,
+		Args: cobra.ArbitraryArgs,
 	}
 )
 
 var dryRunningOption bool
 var forceExcutionOption bool
 var requestOption bool
+var waitingOption bool
+var tierOption string
+var restorePathsOption []string
+var confirmedOption bool
 var validDaysStrOption string
 
 var RunningFromRequestOption string
 var RunningFromLatestRequestOption bool
 
 func restoreCommand(cmd *cobra.Command, args []string) {
+	restorePathsOption = args
 	if err := restoreAction(); err != nil {
 		Exit(err, 1)
 	}
@@ -41,20 +55,35 @@ func init() {
 	restoreCmd.Flags().BoolVarP(&runFastlyOption, "fast", "s", false, "Check fastly with checking timestamp, without checking file hash")
 
 	restoreCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name")
-	restoreCmd.Flags().StringVarP(&commitAliasOption, "commit", "c", "", "commit id")
+	restoreCmd.Flags().StringVarP(&commitAliasOption, "commit", "c", "", "commit id or revision")
 	restoreCmd.Flags().BoolVarP(&requestOption, "request", "q", false, "Send request to restore archived files in AWS S3 Glacier Deep Archive")
 	restoreCmd.Flags().StringVarP(&validDaysStrOption, "valid-days", "v", "3", "valid days of restored archive files")
+	restoreCmd.Flags().StringVarP(&tierOption, "tier", "t", RESTORE_TIER_BULK, "retrieval tier of restoring archived files (Bulk, Standard or Expedited)")
+	restoreCmd.Flags().BoolVarP(&confirmedOption, "yes", "y", false, "Send the restore request without confirmation even if the estimated cost is large")
 	//restoreCmd.Flags().BoolVarP(&RunningFromLatestRequestOption, "run-latest-requested", "l", false, "Download and place files that was requested latestly")
 	restoreCmd.Flags().StringVarP(&RunningFromRequestOption, "run-requested", "e", "", "Download and place files from restore-request")
+	restoreCmd.Flags().BoolVarP(&waitingOption, "wait", "w", false, "Send request to restore archived files, wait until they are available, and restore (with --run-requested, resume waiting)")
 	restoreCmd.Flags().BoolVarP(&debugOption, "debug", "b", false, "Debug print")
+	restoreCmd.Flags().BoolVarP(&oneFileSystemOption, "one-file-system", "x", false, "Do not cross mount points")
+	restoreCmd.Flags().BoolVarP(&requireSignatureOption, "require-signature", "S", false, "Refuse to restore from unsigned history")
+	restoreCmd.Flags().BoolVarP(&noOwnerOption, "no-owner", "o", false, "Do not restore ownership of files")
 }
 
 func restoreAction() (err error) {
 	if RunningFromRequestOption != "" {
+		if len(restorePathsOption) > 0 {
+			return errors.New("Paths cannot be specified with --run-requested. The paths of the restore request are restored")
+		}
 		// Error occures if commitAliasOption, validDaysStrOption, repositoryNameOption or requestOption not is empty.
+		if waitingOption {
+			return restoreActionWaiting(RunningFromRequestOption)
+		}
 		return restoreActionFromRequested(RunningFromRequestOption)
 	}
 
+	if repoName, _ := splitRepositoryRevision(commitAliasOption); repositoryNameOption == "" && repoName != "" {
+		repositoryNameOption = repoName
+	}
 	if repositoryNameOption == "" {
 		return errors.New("Need to specify repository name")
 	}
@@ -62,8 +91,25 @@ func restoreAction() (err error) {
 		return errors.New("Need to specify commit alias")
 	}
 
-	if requestOption {
-		return restoreActionRequest()
+	if waitingOption {
+		repo, err := findRepo(repositoryNameOption)
+		if err != nil {
+			return err
+		}
+		if _, ok := repo.Location.(RepositoryLocationS3); !ok {
+			// blobs of other repositories can be downloaded without restore
+			return restoreActionImmediately()
+		}
+	}
+	if requestOption || waitingOption {
+		restoreRequestId, err := restoreActionRequest()
+		if err != nil || !waitingOption || dryRunningOption {
+			return err
+		}
+		if restoreRequestId == "" {
+			return restoreActionImmediately()
+		}
+		return restoreActionWaiting(restoreRequestId)
 	}
 	// Error occures if validDaysStrOption not is empty.
 	return restoreActionImmediately()
@@ -74,7 +120,7 @@ func restoreActionImmediately() (err error) {
 	if err != nil {
 		return err
 	}
-	remoteCommit, err := remoteRepo.LoadCommitFromAlias(commitAliasOption)
+	remoteRepo, remoteCommit, err := loadRevision(remoteRepo, commitAliasOption)
 	if err != nil {
 		return err
 	}
@@ -86,51 +132,88 @@ func restoreActionImmediately() (err error) {
 	if err != nil {
 		return err
 	}
-	return downloadAndReplace(remoteRepo, localCommit, remoteCommit)
+	return downloadAndReplace(remoteRepo, localCommit, remoteCommit, restorePathsOption)
 }
 
-func restoreActionRequest() (err error) {
+// restoreActionRequest sends the restore request and returns the id.
+// The id is empty if the request is unnecessary or not sent.
+func restoreActionRequest() (restoreRequestId string, err error) {
 	validDaysI, err := strconv.ParseInt(validDaysStrOption, 10, 32)
 	if err != nil {
-		return err
+		return "", err
 	}
 	validDays := int32(validDaysI)
+	tier, err := findRestoreTier(tierOption)
+	if err != nil {
+		return "", err
+	}
 
 	selfRepo := SelfRepo()
 	localCommit, err := createCommitStructure()
 	if err != nil {
-		return err
+		return "", err
 	}
 	remoteRepo, err := findRepo(repositoryNameOption)
 	if err != nil {
-		return err
+		return "", err
 	}
-	remoteCommit, err := remoteRepo.LoadCommitFromAlias(commitAliasOption)
+	remoteRepo, remoteCommit, err := loadRevision(remoteRepo, commitAliasOption)
 	if err != nil {
-		return err
+		return "", err
+	}
+	err = verifyHistory(remoteRepo, remoteCommit, true)
+	if err != nil {
+		return "", err
 	}
 
 	localBlobs, err := selfRepo.FetchBlobHashes()
 	if err != nil {
-		return err
+		return "", err
 	}
-	blobsToReceive := blobsShouldReceive(localBlobs, localCommit.Tags, remoteCommit.Tags)
+	localTags, remoteTags, err := selectRestoringTags(localCommit, remoteCommit, restorePathsOption)
+	if err != nil {
+		return "", err
+	}
+	blobsToReceive := blobsShouldReceive(localBlobs, localTags, remoteTags)
 	if len(blobsToReceive) == 0 {
 		message("Restore request is unnecessary. You can excute restore immediately.")
-		return nil
+		return "", nil
 	}
 
+	if size, known := sizeOfTags(blobsToReceive, true); known {
+		message("requesting " + strconv.Itoa(len(blobsToReceive)) + " files, " + size2string(size))
+	}
+	if waitingOption && !dryRunningOption {
+		// checked before sending the request, because a request costs even if restoring is refused after waiting
+		err = checkLatestCommitSaved(localCommit)
+		if err != nil {
+			return "", err
+		}
+		err = checkFreeSpace(blobsToReceive)
+		if err != nil {
+			return "", err
+		}
+	}
+	if location, ok := remoteRepo.Location.(RepositoryLocationS3); ok {
+		sending, err := previewRestoreRequest(location, blobsToReceive, tier, confirmedOption || dryRunningOption)
+		if err != nil {
+			return "", err
+		}
+		if !sending {
+			return "", errors.New("The restore request is canceled")
+		}
+	}
 	if dryRunningOption {
 		message("Show requesting blobs if you excute 'restore --request'.")
 		for _, tag := range blobsToReceive {
 			messageStdin("request: " + tag.Hash.String() + ", will locate to: " + tag.Path)
 		}
-		return nil
+		return "", nil
 	}
 
-	blobs, err := remoteRepo.ReceiveRemoteBlobsRequest(blobsToReceive, validDays)
+	blobs, err := remoteRepo.ReceiveRemoteBlobsRequest(blobsToReceive, validDays, tier)
 	if err != nil {
-		return err
+		return "", err
 	}
 	restoreReqeustId := timestamp2string(timestampNow())
 	err = selfRepo.WriteRestoreRequest(restoreReqeustId, RestoreRequest{
@@ -138,12 +221,14 @@ func restoreActionRequest() (err error) {
 		ValidDays:  validDays,
 		Commit:     remoteCommit,
 		Blobs:      blobs,
+		Tier:       tier,
+		Paths:      restorePathsOption,
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 	message("Sending request is success!\n restore-request:" + restoreReqeustId)
-	return nil
+	return restoreReqeustId, nil
 }
 
 func restoreActionFromRequested(restoreRequestIdAlias string) error {
@@ -158,14 +243,70 @@ func restoreActionFromRequested(restoreRequestIdAlias string) error {
 		return err
 	}
 	message("restore-request:" + rId)
-	return downloadAndReplace(req.Repository, localCommit, req.Commit)
+	return downloadAndReplace(req.Repository, localCommit, req.Commit, req.Paths)
+}
+
+// restoreActionWaiting waits until blobs of the restore request are downloaded, and restores files.
+// The restore request and downloaded blobs are saved, so waiting can be resumed with the same command after an interruption.
+func restoreActionWaiting(restoreRequestIdAlias string) error {
+	selfRepo := SelfRepo()
+	rId, req, err := selfRepo.LoadRestoreRequest(restoreRequestIdAlias)
+	if err != nil {
+		return err
+	}
+	message("restore-request:" + rId)
+	// checked before waiting, because files can not be restored after waiting for hours
+	localCommit, err := createCommitStructure()
+	if err != nil {
+		return err
+	}
+	err = checkLatestCommitSaved(localCommit)
+	if err != nil {
+		return err
+	}
+	localBlobs, err := selfRepo.FetchBlobHashes()
+	if err != nil {
+		return err
+	}
+	// blobs downloaded before an interruption are not received again
+	err = checkFreeSpace(blobsShouldReceive(localBlobs, nil, requestedTags(req)))
+	if err != nil {
+		return err
+	}
+	message("Waiting for the restore. If it is interrupted, resume with 'arciv restore --run-requested " + rId + " --wait'")
+	err = waitForRestoredBlobs(req)
+	if err != nil {
+		return err
+	}
+	// files may be changed while waiting
+	localCommit, err = createCommitStructure()
+	if err != nil {
+		return err
+	}
+	return downloadAndReplace(req.Repository, localCommit, req.Commit, req.Paths)
+}
+
+// checkLatestCommitSaved returns an error if files of the self repository are changed from the latest commit,
+// because they are replaced by restoring
+func checkLatestCommitSaved(localCommit Commit) error {
+	if forceExcutionOption || dryRunningOption {
+		return nil
+	}
+	localLatestCommitId, err := SelfRepo().LoadLatestCommitId()
+	if err != nil {
+		return err
+	}
+	if localCommit.Id[9:] != localLatestCommitId[9:] {
+		return errors.New("Directory structure is not saved with latest commit")
+	}
+	return nil
 }
 
 func blobsShouldReceive(localBlobs []string, localTags []Tag, remoteTags []Tag) (blobsToReceive []Tag) {
-	for _, lTag := range localTags {
+	for _, lTag := range blobTags(localTags) {
 		localBlobs = append(localBlobs, lTag.Hash.String())
 	}
-	for _, rTag := range remoteTags {
+	for _, rTag := range blobTags(remoteTags) {
 		if !isIncluded(localBlobs, rTag.Hash.String()) {
 			blobsToReceive = append(blobsToReceive, rTag)
 		}
@@ -173,23 +314,92 @@ func blobsShouldReceive(localBlobs []string, localTags []Tag, remoteTags []Tag)
 	return blobsToReceive
 }
 
-func downloadAndReplace(remoteRepo Repository, localCommit, remoteCommit Commit) error {
-	selfRepo := SelfRepo()
-	if !forceExcutionOption {
-		localLatestCommitId, err := selfRepo.LoadLatestCommitId()
+// checkFreeSpace returns an error if the disk of the self repository does not have space to receive blobs
+func checkFreeSpace(blobsToReceive []Tag) error {
+	size, known := sizeOfTags(blobsToReceive, true)
+	if !known {
+		return nil
+	}
+	free, err := fileOp.freeSpace(fileOp.rootDir())
+	if err == errFreeSpaceUnknown {
+		message("receiving " + strconv.Itoa(len(blobsToReceive)) + " files, " + size2string(size))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	message("receiving " + strconv.Itoa(len(blobsToReceive)) + " files, " + size2string(size) + " (free space: " + size2string(free) + ")")
+	if free < size {
+		return errors.New("Free disk space is not enough to receive " + size2string(size))
+	}
+	return nil
+}
+
+// checkReceivedBlobSizes returns an error if a received blob is truncated
+func checkReceivedBlobSizes(blobsReceived []Tag) error {
+	for _, tag := range blobsReceived {
+		if !tag.UsedSize {
+			continue
+		}
+		size, err := fileOp.sizeFile(arcivDir() + "/blob/" + tag.Hash.String())
 		if err != nil {
 			return err
 		}
-		if localCommit.Id[9:] != localLatestCommitId[9:] && !dryRunningOption {
-			return errors.New("Directory structure is not saved with latest commit")
+		if size != tag.Size {
+			return errors.New("The received blob " + tag.Hash.String() + " is truncated (" + strconv.FormatInt(size, 10) + " of " + strconv.FormatInt(tag.Size, 10) + " bytes)")
 		}
 	}
+	return nil
+}
+
+// selectRestoringTags selects tags of the self repository to replace and tags of the commit to restore.
+// All tags are selected if paths is empty.
+func selectRestoringTags(localCommit, remoteCommit Commit, paths []string) (localTags []Tag, remoteTags []Tag, err error) {
+	localTags = selectRestoreTags(localCommit.Tags, paths)
+	remoteTags = detachHardlinks(selectRestoreTags(remoteCommit.Tags, paths))
+	if len(paths) > 0 && len(localTags) == 0 && len(remoteTags) == 0 {
+		return nil, nil, errors.New("No files match the paths " + strings.Join(paths, ", "))
+	}
+	return localTags, remoteTags, nil
+}
+
+// downloadAndReplace restores files of the commit. Only files matching paths are replaced if paths is not empty.
+func downloadAndReplace(remoteRepo Repository, localCommit, remoteCommit Commit, paths []string) error {
+	selfRepo := SelfRepo()
+	err := verifyHistory(remoteRepo, remoteCommit, true)
+	if err != nil {
+		return err
+	}
+	err = checkLatestCommitSaved(localCommit)
+	if err != nil {
+		return err
+	}
+	latestId, relation, err := latestRelation(selfRepo, remoteCommit.Id, selfRepo, remoteRepo)
+	if err != nil {
+		return err
+	}
+	switch relation {
+	case RELATION_BEHIND:
+		message("The commit '" + remoteCommit.Id + "' is older than the latest commit '" + latestId + "' of the self repository")
+	case RELATION_DIVERGED:
+		message("warning: the commit '" + remoteCommit.Id + "' diverges from the latest commit '" + latestId + "' of the self repository")
+	}
 
 	localBlobs, err := selfRepo.FetchBlobHashes()
 	if err != nil {
 		return err
 	}
-	blobsToReceive := blobsShouldReceive(localBlobs, localCommit.Tags, remoteCommit.Tags)
+	localTags, remoteTags, err := selectRestoringTags(localCommit, remoteCommit, paths)
+	if err != nil {
+		return err
+	}
+	// only blobs of files replaced by stashing can be used
+	blobsToReceive := blobsShouldReceive(localBlobs, localTags, remoteTags)
+	// paths differing only in case or normalization overwrite each other on some filesystems
+	err = checkPathCollisions(tagsAfterRestoring(localCommit, localTags, remoteTags))
+	if err != nil {
+		return err
+	}
 
 	// download
 	if dryRunningOption {
@@ -199,10 +409,36 @@ func downloadAndReplace(remoteRepo Repository, localCommit, remoteCommit Commit)
 		}
 		return nil
 	}
+	err = checkOwnership(remoteTags)
+	if err != nil {
+		return err
+	}
+	err = checkFreeSpace(blobsToReceive)
+	if err != nil {
+		return err
+	}
 	err = remoteRepo.ReceiveRemoteBlobs(blobsToReceive)
 	if err != nil {
 		return err
 	}
+	err = checkReceivedBlobSizes(blobsToReceive)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) > 0 {
+		err = stashTagsPartially(localTags)
+		if err != nil {
+			return err
+		}
+		err = unstashTags(remoteTags)
+		if err != nil {
+			return err
+		}
+		// the files are a mix of the commit and the latest commit of the self repository
+		message("restored " + strconv.Itoa(len(remoteTags)) + " files of the commit '" + remoteCommit.Id + "'. Record the files with 'arciv commit'")
+		return nil
+	}
 
 	// mv all local files to .arciv/blob
 	err = stashTags(localCommit.Tags)
@@ -215,5 +451,6 @@ func downloadAndReplace(remoteRepo Repository, localCommit, remoteCommit Commit)
 		return err
 	}
 
-	return selfRepo.AddCommit(remoteCommit)
+	_, err = selfRepo.AddCommit(remoteCommit)
+	return err
 }
@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+	"strconv"
+	"time"
+)
+
+var (
+	restoreRequestCmd = &cobra.Command{
+		Use:   "restore-request ( list | show <restore-request-id> | status <restore-request-id> | remove <restore-request-id> )",
+		Run:   restoreRequestCommand,
+		Short: "List, show, check or remove restore requests",
+		Long: `List, show, check or remove restore requests sent by 'arciv restore --request'.
+'status' looks up the restore state of each requested blob in AWS S3.
+A request is expired if the restored copies may have been deleted (the max time to complete the restore with the tier and the valid days have passed).
+Example:
+        arciv restore-request list
+          ... list restore requests
+        arciv restore-request status 6530a8
+          ... show whether requested blobs are still thawing or can be downloaded
+        arciv restore-request remove --expired
+          ... remove expired restore requests`,
+	}
+)
+
+var removingExpiredOption bool
+
+func restoreRequestCommand(cmd *cobra.Command, args []string) {
+	if err := restoreRequestAction(args); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(restoreRequestCmd)
+	restoreRequestCmd.Flags().BoolVarP(&removingExpiredOption, "expired", "", false, "Remove all expired restore requests (with 'remove')")
+}
+
+func restoreRequestAction(args []string) error {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "list") {
+		return restoreRequestActionList()
+	}
+	if len(args) == 2 && args[0] == "show" {
+		return restoreRequestActionShow(args[1])
+	}
+	if len(args) == 2 && args[0] == "status" {
+		return restoreRequestActionStatus(args[1])
+	}
+	if len(args) == 2 && args[0] == "remove" && !removingExpiredOption {
+		return restoreRequestActionRemove(args[1])
+	}
+	if len(args) == 1 && args[0] == "remove" && removingExpiredOption {
+		return restoreRequestActionRemoveExpired()
+	}
+	message("Usage: arciv restore-request [list]")
+	message("       arciv restore-request show [restore-request id]")
+	message("       arciv restore-request status [restore-request id]")
+	message("       arciv restore-request remove [restore-request id]")
+	message("       arciv restore-request remove --expired")
+	return nil
+}
+
+func unix2string(t int64) string {
+	return time.Unix(t, 0).Format(revisionDateLayouts[1])
+}
+
+// restoreRequestLine returns a line to list the restore request
+func restoreRequestLine(id string, request RestoreRequest, now int64) (string, error) {
+	requested, err := restoreRequestTime(id)
+	if err != nil {
+		return "", err
+	}
+	line := id + " requested:" + unix2string(requested) + " repository:" + request.Repository.Name +
+		" commit:" + request.Commit.Id + " blobs:" + strconv.Itoa(len(request.Blobs)) +
+		" tier:" + request.Tier + " valid-days:" + strconv.Itoa(int(request.ValidDays)) + " expires:" + unix2string(request.expiry(requested))
+	if now > request.expiry(requested) {
+		line += " (expired)"
+	}
+	return line, nil
+}
+
+func restoreRequestActionList() error {
+	selfRepo := SelfRepo()
+	ids, err := selfRepo.LoadRestoreRequestIds()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		request, err := selfRepo.LoadRestoreRequestHeader(id)
+		if err != nil {
+			message("warning: the restore request " + id + " is invalid: " + err.Error())
+			continue
+		}
+		line, err := restoreRequestLine(id, request, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+		messageStdin(line)
+	}
+	return nil
+}
+
+func restoreRequestActionShow(alias string) error {
+	id, request, err := SelfRepo().LoadRestoreRequest(alias)
+	if err != nil {
+		return err
+	}
+	line, err := restoreRequestLine(id, request, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	messageStdin(line)
+	for _, blob := range request.Blobs {
+		messageStdin(blob + " " + restoreRequestBlobPath(request, blob))
+	}
+	return nil
+}
+
+// restoreRequestBlobPath returns the first path of the blob in the commit of the request
+func restoreRequestBlobPath(request RestoreRequest, blob string) string {
+	for _, tag := range blobTags(request.Commit.Tags) {
+		if tag.Hash.String() == blob {
+			return tag.Path
+		}
+	}
+	return ""
+}
+
+func restoreRequestActionStatus(alias string) error {
+	id, request, err := SelfRepo().LoadRestoreRequest(alias)
+	if err != nil {
+		return err
+	}
+	location, ok := request.Repository.Location.(RepositoryLocationS3)
+	if !ok {
+		return errors.New("The repository " + request.Repository.Name + " of the restore request is not a repository of AWS S3")
+	}
+	now := time.Now().Unix()
+	line, err := restoreRequestLine(id, request, now)
+	if err != nil {
+		return err
+	}
+	messageStdin(line)
+
+	counts := make(map[string]int)
+	for _, blob := range request.Blobs {
+		object, err := s3Op.headObject(location.RegionName, location.BucketName, ".arciv/blob/"+blob)
+		if err != nil {
+			return err
+		}
+		status := restoreStatus(object)
+		counts[status]++
+		line := status + " " + blob
+		if status == RESTORE_STATUS_AVAILABLE && object.Expiry != 0 {
+			line += " until:" + unix2string(object.Expiry)
+		}
+		messageStdin(line + " " + restoreRequestBlobPath(request, blob))
+	}
+
+	message(strconv.Itoa(counts[RESTORE_STATUS_AVAILABLE]+counts[RESTORE_STATUS_NOT_ARCHIVED]) + " blobs can be downloaded, " +
+		strconv.Itoa(counts[RESTORE_STATUS_ONGOING]) + " blobs are thawing, " + strconv.Itoa(counts[RESTORE_STATUS_ARCHIVED]) + " blobs are archived")
+	switch {
+	case counts[RESTORE_STATUS_ARCHIVED] > 0:
+		message("Some blobs are not restored. Send the restore request again with 'arciv restore --request'")
+	case counts[RESTORE_STATUS_ONGOING] > 0:
+		message("Wait for the restore to complete")
+	default:
+		message("Ready. Excute 'arciv restore --run-requested " + id + "'")
+	}
+	return nil
+}
+
+func removeRestoreRequest(id string) error {
+	err := fileOp.removeFile(arcivDir() + "/restore-request/" + id)
+	if err != nil {
+		return err
+	}
+	message("removed restore-request:" + id)
+	return nil
+}
+
+func restoreRequestActionRemove(alias string) error {
+	ids, err := SelfRepo().LoadRestoreRequestIds()
+	if err != nil {
+		return err
+	}
+	id, err := findRestoreRequestId(alias, ids)
+	if err != nil {
+		return err
+	}
+	return removeRestoreRequest(id)
+}
+
+func restoreRequestActionRemoveExpired() error {
+	selfRepo := SelfRepo()
+	ids, err := selfRepo.LoadRestoreRequestIds()
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, id := range ids {
+		request, err := selfRepo.LoadRestoreRequestHeader(id)
+		if err != nil {
+			message("warning: the restore request " + id + " is invalid: " + err.Error())
+			continue
+		}
+		requested, err := restoreRequestTime(id)
+		if err != nil {
+			return err
+		}
+		if now > request.expiry(requested) {
+			err = removeRestoreRequest(id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
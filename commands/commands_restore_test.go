@@ -1,3 +1,143 @@
 package commands
 
+import (
+	"strings"
+	"testing"
+)
+
 // FIXME: Please write tests
+
+func TestRestoreFreeSpace(t *testing.T) {
+	tags := []Tag{{Path: "a.txt", Size: 4096, UsedSize: true}}
+
+	// func checkFreeSpace(blobsToReceive []Tag) error
+	t.Run("checkFreeSpace()", func(t *testing.T) {
+		free := int64(1024)
+		var freeErr error
+		fileOp = &FileOp{
+			rootDir: func() string {
+				return "/root"
+			},
+			freeSpace: func(path string) (int64, error) {
+				return free, freeErr
+			},
+		}
+		if checkFreeSpace(tags) == nil {
+			t.Errorf("checkFreeSpace() return nil without enough space, want an error")
+		}
+		free = 8192
+		if err := checkFreeSpace(tags); err != nil {
+			t.Errorf("checkFreeSpace() return an error \"%s\" with enough space, want nil", err)
+		}
+		// the platform can not get free disk space
+		free, freeErr = 0, errFreeSpaceUnknown
+		if err := checkFreeSpace(tags); err != nil {
+			t.Errorf("checkFreeSpace() return an error \"%s\" on an unknown free space, want nil", err)
+		}
+	})
+}
+
+func TestRestoreRequestWaiting(t *testing.T) {
+	algorithm := currentHashAlgorithm()
+	local := algorithm.hashBytes([]byte("local"))
+	remote := algorithm.hashBytes([]byte("remote"))
+	localId := "00000000-" + hashTags(algorithm, []Tag{{Path: "a.txt", Hash: local}}).String()
+	remoteId := "11111111-" + hashTags(algorithm, []Tag{{Path: "b.txt", Hash: remote}}).String()
+
+	var localHash Hash
+	var free int64
+	files := map[string][]string{
+		"/root/.arciv/repositories": {"name:s3-repo type:s3 region:region bucket:bucket"},
+		"/root/.arciv/timeline":     {localId},
+	}
+	fileOp = &FileOp{
+		rootDir: func() string {
+			return "/root"
+		},
+		findFilePaths: func(root string) ([]string, error) {
+			if root == "/root" {
+				return []string{"a.txt"}, nil
+			}
+			return []string{}, nil
+		},
+		findDirPaths: func(root string) ([]string, error) {
+			return []string{}, nil
+		},
+		statEntry: func(path string) (FileEntry, error) {
+			return FileEntry{Type: TAG_TYPE_FILE}, nil
+		},
+		hashFile: func(path string) (Hash, error) {
+			return localHash, nil
+		},
+		timestampFile: func(path string) (int64, error) {
+			return 0, nil
+		},
+		sizeFile: func(path string) (int64, error) {
+			return 5, nil
+		},
+		freeSpace: func(path string) (int64, error) {
+			return free, nil
+		},
+		loadLines: func(path string) ([]string, error) {
+			return files[path], nil
+		},
+		writeLines: func(path string, lines []string) error {
+			files[path] = lines
+			return nil
+		},
+		isExist: func(path string) (bool, error) {
+			_, ok := files[path]
+			return ok, nil
+		},
+	}
+	objects := map[string][]string{
+		".arciv/timeline":                   {remoteId},
+		".arciv/list/" + remoteId:           {"#arciv-commit-atom", remote.String() + " b.txt"},
+		".arciv/list/" + remoteId + ".size": {"#arciv-sizes of:" + remoteId, "4096"},
+	}
+	var requested []string
+	s3Op = &S3Op{
+		loadLines: func(region string, bucket string, path string) ([]string, error) {
+			return objects[path], nil
+		},
+		isExist: func(region string, bucket string, path string) (bool, error) {
+			_, ok := objects[path]
+			return ok, nil
+		},
+		headObject: func(region string, bucket string, name string) (S3Object, error) {
+			return S3Object{StorageClass: "DEEP_ARCHIVE", Size: 4096}, nil
+		},
+		receiveBlobsRequest: func(region string, bucket string, names []string, validDays int32, tier string) ([]string, error) {
+			requested = append(requested, names...)
+			return names, nil
+		},
+	}
+	repositoryNameOption, commitAliasOption, waitingOption, confirmedOption = "s3-repo", remoteId, true, true
+	defer func() {
+		repositoryNameOption, commitAliasOption, waitingOption, confirmedOption = "", "", false, false
+	}()
+
+	// func restoreActionRequest() (restoreRequestId string, err error)
+	cases := []struct {
+		name      string
+		localHash Hash
+		free      int64
+		sending   bool
+	}{
+		{"files are changed from the latest commit", algorithm.hashBytes([]byte("changed")), 8192, false},
+		{"free disk space is not enough", local, 1024, false},
+		{"all checks pass", local, 8192, true},
+	}
+	for _, c := range cases {
+		t.Run("restoreActionRequest() with --wait when "+c.name, func(t *testing.T) {
+			localHash, free, requested = c.localHash, c.free, nil
+			_, err := restoreActionRequest()
+			if c.sending && (err != nil || strings.Join(requested, ",") != ".arciv/blob/"+remote.String()) {
+				t.Errorf("restoreActionRequest() = %v, and requests %v, want nil and the blob", err, requested)
+			}
+			if !c.sending && (err == nil || len(requested) != 0) {
+				t.Errorf("restoreActionRequest() = %v, and requests %v, want an error without requests", err, requested)
+			}
+		})
+	}
+}
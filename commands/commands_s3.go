@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+	"strconv"
+	"time"
+)
+
+var (
+	s3Cmd = &cobra.Command{
+		Use:   "s3",
+		Short: "Manage blobs stored in AWS S3",
+		Long:  "Manage blobs stored in a repository of AWS S3",
+	}
+	s3TransitionCmd = &cobra.Command{
+		Use:   "transition",
+		Run:   s3TransitionCommand,
+		Short: "Change the storage class of stored blobs",
+		Long: `Change the storage class of blobs stored in a repository of AWS S3 with server-side copies.
+The cost and the penalty of the minimum storage duration are estimated and shown before changing.
+Blobs in archived classes (GLACIER, DEEP_ARCHIVE) are restored first. Excute the command again after the restore is completed to copy them.
+Example:
+        arciv s3 transition --repository aws-s3-repo --class GLACIER_IR --paths 'projects/2024/**' --dry-run
+          ... estimate the cost to change blobs of files under projects/2024 in the latest commit to GLACIER_IR
+        arciv s3 transition --repository aws-s3-repo --class GLACIER_IR --paths 'projects/2024/**'
+          ... change them`,
+		Args: cobra.NoArgs,
+	}
+	s3PolicyCmd = &cobra.Command{
+		Use:   "policy ( list | add <storage-class> | remove <number> )",
+		Run:   s3PolicyCommand,
+		Short: "List, add or remove rules of the storage policy",
+		Long: `List, add or remove rules of the storage policy of a repository of AWS S3.
+The storage class of a blob sent by 'arciv store' is chosen by the first rule which the file matches.
+A rule matches if all of the specified conditions match. Blobs which no rule matches are stored in ` + STORAGE_CLASS_DEFAULT + `.
+The policy does not change blobs already stored. Use 'arciv s3 transition' to change them.
+Example:
+        arciv s3 policy -r aws-s3-repo add STANDARD_IA --smaller 1M
+          ... store files smaller than 1MiB in STANDARD_IA
+        arciv s3 policy -r aws-s3-repo add GLACIER_IR --paths 'projects/2024/**' --newer 365
+          ... store files under projects/2024 modified within a year in GLACIER_IR
+        arciv s3 policy -r aws-s3-repo remove 1
+          ... remove the first rule`,
+	}
+)
+
+var storageClassOption string
+var transitionPathsOption string
+var policyPathsOption string
+var policySmallerOption string
+var policyLargerOption string
+var policyOlderOption int64
+var policyNewerOption int64
+
+func s3TransitionCommand(cmd *cobra.Command, args []string) {
+	if err := s3TransitionAction(); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(s3Cmd)
+	s3Cmd.AddCommand(s3TransitionCmd)
+	s3TransitionCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name of AWS S3")
+	s3TransitionCmd.Flags().StringVarP(&storageClassOption, "class", "", "", "storage class to change to")
+	s3TransitionCmd.Flags().StringVarP(&transitionPathsOption, "paths", "p", "", "glob pattern of file paths to change ('**' matches directories). All files if it is empty")
+	s3TransitionCmd.Flags().StringVarP(&commitAliasOption, "commit", "c", "", "commit whose files are changed (the latest commit if it is empty)")
+	s3TransitionCmd.Flags().BoolVarP(&dryRunningOption, "dry-run", "d", false, "Show the plan and the estimated cost without changing")
+	s3TransitionCmd.Flags().StringVarP(&validDaysStrOption, "valid-days", "v", "3", "valid days of restored archive files")
+
+	s3Cmd.AddCommand(s3PolicyCmd)
+	s3PolicyCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name of AWS S3")
+	s3PolicyCmd.Flags().StringVarP(&policyPathsOption, "paths", "p", "", "glob pattern of file paths ('**' matches directories)")
+	s3PolicyCmd.Flags().StringVarP(&policySmallerOption, "smaller", "", "", "size which files are smaller than (ex. 512K, 1M)")
+	s3PolicyCmd.Flags().StringVarP(&policyLargerOption, "larger", "", "", "size which files are larger than or equal to (ex. 1G)")
+	s3PolicyCmd.Flags().Int64VarP(&policyOlderOption, "older", "", 0, "days which files are modified more than ago")
+	s3PolicyCmd.Flags().Int64VarP(&policyNewerOption, "newer", "", 0, "days which files are modified within")
+}
+
+func s3PolicyCommand(cmd *cobra.Command, args []string) {
+	if err := s3PolicyAction(args); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func s3TransitionAction() error {
+	if _, err := findStorageClassPrice(storageClassOption); err != nil {
+		return err
+	}
+	validDaysI, err := strconv.ParseInt(validDaysStrOption, 10, 32)
+	if err != nil {
+		return err
+	}
+	validDays := int32(validDaysI)
+
+	repo, location, err := findS3Repo(repositoryNameOption)
+	if err != nil {
+		return err
+	}
+	commitAlias := commitAliasOption
+	if commitAlias == "" {
+		commitAlias = REVISION_LATEST
+	}
+	_, commit, err := loadRevision(repo, commitAlias)
+	if err != nil {
+		return err
+	}
+	tags := selectTransitionTags(commit.Tags, transitionPathsOption)
+	if len(tags) == 0 {
+		message("No files match the paths in the commit '" + commit.Id + "'")
+		return nil
+	}
+
+	plan, err := planTransition(location, tags, storageClassOption)
+	if err != nil {
+		return err
+	}
+	cost, err := estimateTransition(plan, validDays, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	for _, line := range plan.Strings() {
+		messageStdin(line)
+	}
+	message("Estimated cost (approximate prices of us-east-1):")
+	for _, line := range cost.Strings() {
+		messageStdin(line)
+	}
+	if dryRunningOption {
+		return nil
+	}
+	return runTransition(location, plan, validDays)
+}
+
+// findS3Repo finds a repository of AWS S3 by the name
+func findS3Repo(name string) (Repository, RepositoryLocationS3, error) {
+	if name == "" {
+		return Repository{}, RepositoryLocationS3{}, errors.New("Need to specify the repository name")
+	}
+	repo, err := findRepo(name)
+	if err != nil {
+		return Repository{}, RepositoryLocationS3{}, err
+	}
+	location, ok := repo.Location.(RepositoryLocationS3)
+	if !ok {
+		return Repository{}, RepositoryLocationS3{}, errors.New("The repository " + repo.Name + " is not a repository of AWS S3")
+	}
+	return repo, location, nil
+}
+
+func s3PolicyAction(args []string) error {
+	_, location, err := findS3Repo(repositoryNameOption)
+	if err != nil {
+		return err
+	}
+	rules, err := loadStoragePolicy(location)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || (len(args) == 1 && args[0] == "list") {
+		for i, rule := range rules {
+			messageStdin(strconv.Itoa(i+1) + " " + rule.Line())
+		}
+		messageStdin("default class:" + STORAGE_CLASS_DEFAULT)
+		return nil
+	}
+	if len(args) == 2 && args[0] == "add" {
+		rule, err := policyRuleFromOptions(args[1])
+		if err != nil {
+			return err
+		}
+		err = writeStoragePolicy(location, append(rules, rule))
+		if err != nil {
+			return err
+		}
+		message("added rule " + strconv.Itoa(len(rules)+1) + ": " + rule.Line())
+		return nil
+	}
+	if len(args) == 2 && args[0] == "remove" {
+		number, err := strconv.Atoi(args[1])
+		if err != nil || number < 1 || number > len(rules) {
+			return errors.New("The rule number must be from 1 to " + strconv.Itoa(len(rules)))
+		}
+		message("removed rule " + args[1] + ": " + rules[number-1].Line())
+		return writeStoragePolicy(location, append(rules[:number-1], rules[number:]...))
+	}
+	message("Usage: arciv s3 policy -r [repository name] [list]")
+	message("       arciv s3 policy -r [repository name] add [storage class] [--paths glob] [--smaller size] [--larger size] [--older days] [--newer days]")
+	message("       arciv s3 policy -r [repository name] remove [rule number]")
+	return nil
+}
+
+func policyRuleFromOptions(class string) (rule StoragePolicyRule, err error) {
+	if _, err := findStorageClassPrice(class); err != nil {
+		return StoragePolicyRule{}, err
+	}
+	rule = StoragePolicyRule{Class: class, Paths: policyPathsOption, Older: policyOlderOption, Newer: policyNewerOption}
+	if policySmallerOption != "" {
+		rule.Smaller, err = str2size(policySmallerOption)
+		if err != nil {
+			return StoragePolicyRule{}, err
+		}
+	}
+	if policyLargerOption != "" {
+		rule.Larger, err = str2size(policyLargerOption)
+		if err != nil {
+			return StoragePolicyRule{}, err
+		}
+	}
+	if rule.Older < 0 || rule.Newer < 0 {
+		return StoragePolicyRule{}, errors.New("The days must not be negative")
+	}
+	return rule, nil
+}
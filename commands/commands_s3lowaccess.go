@@ -52,7 +52,7 @@ func s3lowaccessAction(args []string) error {
 	}
 	if len(args) == 5 && args[0] == "upload" {
 		// arciv s3lowaccess upload <region> <bucket> <key> <read-path>
-		return s3Op.sendBlobs(args[1], args[2], []string{args[4]}, []string{args[3]})
+		return s3Op.sendBlobs(args[1], args[2], []string{args[4]}, []string{args[3]}, []string{"DEEP_ARCHIVE"})
 	}
 	if len(args) == 4 && args[0] == "write" {
 		// arciv s3lowaccess write <region> <bucket> <key> # read from stdin
@@ -69,7 +69,7 @@ func s3lowaccessAction(args []string) error {
 		if err != nil {
 			return err
 		}
-		_, err = s3Op.receiveBlobsRequest(args[1], args[2], []string{args[3]}, int32(validDays64))
+		_, err = s3Op.receiveBlobsRequest(args[1], args[2], []string{args[3]}, int32(validDays64), RESTORE_TIER_BULK)
 		return err
 	}
 	fmt.Println("Usage:")
@@ -2,6 +2,8 @@ package commands
 
 import (
 	"github.com/spf13/cobra"
+	"path/filepath"
+	"sort"
 )
 
 var (
@@ -26,12 +28,18 @@ func stashAction() (err error) {
 	if err != nil {
 		return err
 	}
+	commit, err = describeCommit(commit)
+	if err != nil {
+		return err
+	}
 
-	err = SelfRepo().AddCommit(commit)
+	written, err := SelfRepo().AddCommit(commit)
 	if err != nil {
 		return err
 	}
-	message("created commit '" + commit.Id + "'")
+	if written {
+		message("created commit '" + commit.Id + "'")
+	}
 
 	err = stashTags(commit.Tags)
 	if err != nil {
@@ -46,14 +54,9 @@ func stashTags(tags []Tag) (err error) {
 	root := fileOp.rootDir()
 
 	// move all files to .arciv/blob
-	for _, p := range tags {
-		from := root + "/" + p.Path
-		to := root + "/.arciv/blob/" + p.Hash.String()
-		err = fileOp.moveFile(from, to)
-		if err != nil {
-			return err
-		}
-		message("moved " + from + " -> " + to)
+	err = stashEntries(tags)
+	if err != nil {
+		return err
 	}
 
 	// remove all directory in root without .arciv
@@ -62,7 +65,59 @@ func stashTags(tags []Tag) (err error) {
 		return err
 	}
 	for i := len(dirPaths) - 1; i >= 0; i-- {
-		fileOp.removeFile(dirPaths[i])
+		fileOp.removeFile(root + "/" + dirPaths[i])
+	}
+	return nil
+}
+
+// stashTagsPartially stashes files of tags, and removes only directories which become empty.
+// Other files and directories in the self repository are left untouched.
+func stashTagsPartially(tags []Tag) (err error) {
+	err = stashEntries(tags)
+	if err != nil {
+		return err
+	}
+	var dirPaths []string
+	for _, tag := range tags {
+		dir := filepath.Dir(tag.localPath())
+		if tag.entryType() == TAG_TYPE_DIR {
+			dir = tag.localPath()
+		}
+		for ; dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			if !isIncluded(dirPaths, dir) {
+				dirPaths = append(dirPaths, dir)
+			}
+		}
+	}
+	// from the deepest directory. a directory which is not empty is not removed
+	sort.Strings(dirPaths)
+	for i := len(dirPaths) - 1; i >= 0; i-- {
+		fileOp.removeFile(fileOp.rootDir() + "/" + dirPaths[i])
+	}
+	return nil
+}
+
+// stashEntries moves files to .arciv/blob, and removes links and special files
+func stashEntries(tags []Tag) (err error) {
+	root := fileOp.rootDir()
+	for _, p := range tags {
+		from := root + "/" + p.localPath()
+		switch p.entryType() {
+		case TAG_TYPE_FILE:
+			to := arcivDir() + "/blob/" + p.Hash.String()
+			err = fileOp.moveFile(from, to)
+			if err != nil {
+				return err
+			}
+			message("moved " + from + " -> " + to)
+		case TAG_TYPE_SYMLINK, TAG_TYPE_HARDLINK, TAG_TYPE_FIFO, TAG_TYPE_SOCKET, TAG_TYPE_CHARDEV, TAG_TYPE_BLOCKDEV:
+			// recreated from the tag on unstash
+			err = fileOp.removeFile(from)
+			if err != nil {
+				return err
+			}
+			message("removed " + from)
+		}
 	}
 	return nil
 }
@@ -71,4 +126,5 @@ func init() {
 	RootCmd.AddCommand(stashCmd)
 	stashCmd.Flags().BoolVarP(&runFastlyOption, "fast", "s", false, "Check fastly with checking timestamp, without checking file hash")
 	stashCmd.Flags().BoolVarP(&debugOption, "debug", "b", false, "Debug print")
+	stashCmd.Flags().BoolVarP(&oneFileSystemOption, "one-file-system", "x", false, "Do not cross mount points")
 }
@@ -15,6 +15,7 @@ var (
 )
 
 var debugOption bool = false
+var ignoredOption bool
 
 func statusCommand(cmd *cobra.Command, args []string) {
 	if err := statusAction(); err != nil {
@@ -27,9 +28,22 @@ func init() {
 	statusCmd.Flags().BoolVarP(&simplyPrinting, "simple", "m", false, "Print simply")
 	statusCmd.Flags().BoolVarP(&runFastlyOption, "fast", "s", false, "Check fastly with checking timestamp, without checking file hash")
 	statusCmd.Flags().BoolVarP(&debugOption, "debug", "b", false, "Debug print")
+	statusCmd.Flags().BoolVarP(&oneFileSystemOption, "one-file-system", "x", false, "Do not cross mount points")
+	statusCmd.Flags().BoolVarP(&ignoredOption, "ignored", "i", false, "Print files ignored by .arcivignore and the patterns")
 }
 
 func statusAction() (err error) {
+	if ignoredOption {
+		ignored, err := fileOp.findIgnored(fileOp.rootDir())
+		if err != nil {
+			return err
+		}
+		for _, i := range ignored {
+			messageStdin("ignored: " + i.String())
+		}
+		return nil
+	}
+
 	nowCommit, err := createCommitStructure()
 	if err != nil {
 		return err
@@ -40,7 +54,7 @@ func statusAction() (err error) {
 		return err
 	}
 
-	deleted, added := diffTags(latestCommit.Tags, nowCommit.Tags)
+	deleted, added := diffTags(normalizeTags(latestCommit.Tags), nowCommit.Tags)
 	printDiffs(deleted, added)
 	return nil
 }
@@ -3,6 +3,8 @@ package commands
 import (
 	"errors"
 	"github.com/spf13/cobra"
+	"path/filepath"
+	"strconv"
 )
 
 var (
@@ -10,8 +12,12 @@ var (
 		Use:   "store <repository>",
 		Run:   storeCommand,
 		Short: "Store files from the self repository to another repository.",
-		Long:  "Create a commit and send new blobs and timeline to another repository.",
-		Args:  cobra.NoArgs,
+		Long: `Create a commit and send new blobs and timeline to another repository.
+With --commits, send commits already recorded by 'arciv commit' instead of creating a commit.
+Example:
+        arciv store --repository repo-remote --commits a84bfc..
+          ... send the commit 'a84bfc' and the following commits of the self repository`,
+		Args: cobra.NoArgs,
 	}
 )
 
@@ -26,6 +32,8 @@ func init() {
 	storeCmd.Flags().BoolVarP(&runFastlyOption, "fast", "s", false, "Check fastly with checking timestamp, without checking file hash")
 	storeCmd.Flags().StringVarP(&repositoryNameOption, "repository", "r", "", "repository name")
 	storeCmd.Flags().BoolVarP(&debugOption, "debug", "b", false, "Debug print")
+	storeCmd.Flags().BoolVarP(&oneFileSystemOption, "one-file-system", "x", false, "Do not cross mount points")
+	storeCmd.Flags().StringVarP(&commitsOption, "commits", "C", "", "Send local commits in the range '<from>..<to>' (both included, either can be omitted)")
 }
 
 func storeAction(repoName string) (err error) {
@@ -36,12 +44,19 @@ func storeAction(repoName string) (err error) {
 	if err != nil {
 		return err
 	}
+	if commitsOption != "" {
+		return storeCommits(remoteRepo, commitsOption)
+	}
 
 	commit, err := createCommitStructure()
 	if err != nil {
 		return err
 	}
-	err = SelfRepo().AddCommit(commit)
+	commit, err = describeCommit(commit)
+	if err != nil {
+		return err
+	}
+	_, err = SelfRepo().AddCommit(commit)
 	if err != nil {
 		return err
 	}
@@ -54,17 +69,127 @@ func storeAction(repoName string) (err error) {
 
 	// send blobs not stored on remote repository
 	var tagsToSend []Tag
-	for _, tag := range commit.Tags {
+	for _, tag := range blobTags(commit.Tags) {
 		if !isIncluded(remoteHashStrings, tag.Hash.String()) {
 			tagsToSend = append(tagsToSend, tag)
 		}
 	}
+	size, known := sizeOfTags(tagsToSend, true)
+	if known {
+		message("sending " + strconv.Itoa(len(tagsToSend)) + " files, " + size2string(size))
+	}
 	err = remoteRepo.SendLocalBlobs(tagsToSend)
 	if err != nil {
 		return err
 	}
 
-	return remoteRepo.AddCommit(commit)
+	err = warnDivergence(remoteRepo, commit)
+	if err != nil {
+		return err
+	}
+	_, err = remoteRepo.AddCommit(commit)
+	if err != nil {
+		return err
+	}
+	return pushLabels(remoteRepo)
+}
+
+// warnDivergence warns if the latest commit of the repository is not an ancestor of the commit to store,
+// for example, another computer stored a commit to the repository after this computer restored from it.
+func warnDivergence(remoteRepo Repository, commit Commit) error {
+	latestId, relation, err := latestRelation(remoteRepo, commit.Id, SelfRepo(), remoteRepo)
+	if err != nil {
+		return err
+	}
+	// a commit of the same files as the latest commit is not added
+	if relation == RELATION_DIVERGED && latestId[9:] != commit.Id[9:] {
+		message("warning: the commit '" + commit.Id + "' diverges from the latest commit '" + latestId + "' of the repository " + remoteRepo.Name)
+	}
+	return nil
+}
+
+var commitsOption string
+
+// storeCommits sends local commits in the range and their blobs which the remote repository does not have.
+// A blob is read from a file of the same content in the self repository or from .arciv/blob.
+func storeCommits(remoteRepo Repository, commitRange string) error {
+	selfRepo := SelfRepo()
+	timeline, err := selfRepo.LoadTimeline()
+	if err != nil {
+		return err
+	}
+	labels, err := selfRepo.LoadLabels()
+	if err != nil {
+		return err
+	}
+	commitIds, err := findCommitRange(commitRange, timeline, labels)
+	if err != nil {
+		return err
+	}
+	current, err := createCommitStructure()
+	if err != nil {
+		return err
+	}
+	localBlobs, err := selfRepo.FetchBlobHashes()
+	if err != nil {
+		return err
+	}
+	remoteHashStrings, err := remoteRepo.FetchBlobHashes()
+	if err != nil {
+		return err
+	}
+
+	for _, commitId := range commitIds {
+		commit, err := selfRepo.LoadCommit(commitId)
+		if err != nil {
+			return err
+		}
+		var tagsToSend []Tag
+		for _, tag := range blobTags(commit.Tags) {
+			if isIncluded(remoteHashStrings, tag.Hash.String()) {
+				continue
+			}
+			tag, err = localBlobSource(tag, current.Tags, localBlobs)
+			if err != nil {
+				return err
+			}
+			tagsToSend = append(tagsToSend, tag)
+			remoteHashStrings = append(remoteHashStrings, tag.Hash.String())
+		}
+		message("sending commit '" + commit.Id + "' with " + strconv.Itoa(len(tagsToSend)) + " files")
+		err = remoteRepo.SendLocalBlobs(tagsToSend)
+		if err != nil {
+			return err
+		}
+		err = warnDivergence(remoteRepo, commit)
+		if err != nil {
+			return err
+		}
+		_, err = remoteRepo.AddCommit(commit)
+		if err != nil {
+			return err
+		}
+	}
+	return pushLabels(remoteRepo)
+}
+
+// localBlobSource returns the tag with FsPath of a local file which has the content of the tag
+func localBlobSource(tag Tag, currentTags []Tag, localBlobs []string) (Tag, error) {
+	files := blobTags(currentTags)
+	idx := findTagIndex(files, Tag{Hash: tag.Hash}, FIND_HASH)
+	if idx != -1 {
+		tag.FsPath = files[idx].localPath()
+		return tag, nil
+	}
+	if isIncluded(localBlobs, tag.Hash.String()) {
+		path, err := filepath.Rel(fileOp.rootDir(), arcivDir()+"/blob/"+tag.Hash.String())
+		if err != nil {
+			return Tag{}, err
+		}
+		tag.FsPath = path
+		return tag, nil
+	}
+	return Tag{}, errors.New("The content of '" + tag.Path + "' (" + tag.Hash.String() + ") is not found in the self repository")
 }
 
 func isIncluded(strs []string, s string) bool {
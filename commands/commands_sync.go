@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncCmd = &cobra.Command{
+		Use:   "sync",
+		Run:   syncCommand,
+		Short: "Copy commits between repositories",
+		Long: `Copy commits which a repository does not have from another repository, and merge the timelines.
+The merged timeline is ordered by the parents of the commits, so a commit follows its parents even if the clock of a computer runs behind. Labels of the copied commits are also copied.
+Blobs are copied only with --blobs, and blobs between repositories other than the self repository are streamed like 'arciv replicate'.
+Example:
+        arciv sync --from aws-s3-repo
+          ... copy commits stored in 'aws-s3-repo' from other computers to the self repository
+        arciv sync --from media-stable --to aws-s3-repo --blobs
+          ... copy commits and blobs of 'media-stable' to 'aws-s3-repo'`,
+		Args: cobra.NoArgs,
+	}
+)
+
+var syncFromOption string
+var syncToOption string
+var syncBlobsOption bool
+
+func syncCommand(cmd *cobra.Command, args []string) {
+	if err := syncAction(); err != nil {
+		Exit(err, 1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVarP(&syncFromOption, "from", "f", "", "repository name to copy commits from")
+	syncCmd.Flags().StringVarP(&syncToOption, "to", "t", "self", "repository name to copy commits to")
+	syncCmd.Flags().BoolVarP(&syncBlobsOption, "blobs", "B", false, "Copy blobs of the copied commits which the repository does not have")
+	syncCmd.Flags().BoolVarP(&dryRunningOption, "dry-run", "d", false, "Show commits to copy without copying")
+	syncCmd.Flags().BoolVarP(&requireSignatureOption, "require-signature", "S", false, "Refuse to copy from unsigned history")
+}
+
+func syncAction() error {
+	if syncFromOption == "" {
+		return errors.New("Need to specify the repository name to copy commits from")
+	}
+	from, to, err := findRepoPair(syncFromOption, syncToOption)
+	if err != nil {
+		return err
+	}
+	var transfer blobTransfer
+	if syncBlobsOption {
+		transfer = copyBlobs
+	}
+	return transferCommits(from, to, "", transfer)
+}
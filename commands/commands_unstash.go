@@ -36,41 +36,60 @@ func unstashAction() (err error) {
 }
 
 func unstashTags(tags []Tag) (err error) {
+	// a commit may include the metadata of a nested repository, and it must not be overwritten
+	var placing []Tag
+	for _, tag := range tags {
+		if inRepositoryMetadata(tag.Path) {
+			message("warning: skipped " + tag.Path + " in the metadata of a nested repository")
+			continue
+		}
+		placing = append(placing, tag)
+	}
+	tags = placing
+
 	// Guard to check to be able to excute unstash with .arcv/blob list and tags
 	blobs, err := SelfRepo().FetchBlobHashes()
 	if err != nil {
 		return err
 	}
-	for _, tag := range tags {
+	filesTags := blobTags(tags)
+	for _, tag := range filesTags {
 		if !isIncluded(blobs, tag.Hash.String()) {
 			return errors.New("local blob is missing from commit")
 		}
 	}
+	err = checkOwnership(tags)
+	if err != nil {
+		return err
+	}
 
 	root := fileOp.rootDir()
 	// mkdir
 	dirSet := make(map[string]struct{})
 	for _, tag := range tags {
 		dirSet[filepath.Dir(tag.Path)] = struct{}{}
+		if tag.entryType() == TAG_TYPE_DIR || tag.entryType() == TAG_TYPE_NESTED {
+			dirSet[tag.Path] = struct{}{}
+		}
 	}
 	for dir, _ := range dirSet {
-		err = fileOp.mkdirAll(dir)
+		err = fileOp.mkdirAll(root + "/" + dir)
 		if err != nil {
 			return err
 		}
 	}
 
 	// copy or move
-	for i, tag := range tags {
-		from := root + "/.arciv/blob/" + tag.Hash.String()
+	for i, tag := range filesTags {
+		from := arcivDir() + "/blob/" + tag.Hash.String()
 		to := root + "/" + tag.Path
 
 		// If different files point to a same blob,
 		//  the blob is copied on the first (, second, and ...) time,
 		//  and moved on the last time
 		keepInBlobDir := false
-		for j := i + 1; j < len(tags); j++ {
-			if bytes.Compare(tags[j].Hash, tag.Hash) == 0 {
+		for j := i + 1; j < len(filesTags); j++ {
+			if bytes.Compare(filesTags[j].Hash, tag.Hash) == 0 {
 				keepInBlobDir = true
 			}
 		}
@@ -89,10 +108,60 @@ func unstashTags(tags []Tag) (err error) {
 		}
 		message(msg + from + " -> " + to)
 	}
+
+	// symbolic links and hardlinks
+	for _, tag := range tags {
+		to := root + "/" + tag.Path
+		switch tag.entryType() {
+		case TAG_TYPE_SYMLINK:
+			err = fileOp.symlink(tag.Target, to)
+			if err != nil {
+				return err
+			}
+			message("linked " + to + " -> " + tag.Target)
+		case TAG_TYPE_HARDLINK:
+			err = fileOp.link(root+"/"+tag.Target, to)
+			if err != nil {
+				return err
+			}
+			message("hardlinked " + to + " = " + root + "/" + tag.Target)
+		case TAG_TYPE_SOCKET:
+			// a socket is created by a program listening on it
+			message("warning: skipped a socket: " + to)
+		case TAG_TYPE_FIFO, TAG_TYPE_CHARDEV, TAG_TYPE_BLOCKDEV:
+			err = fileOp.mknod(to, tag)
+			if err != nil {
+				return err
+			}
+			message("created " + to + " (" + tag.entryType() + ")")
+		case TAG_TYPE_NESTED:
+			err = placeRepository(root, tag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// extended attributes are applied before metadata, because a read-only file rejects setting them
+	err = applyXattrs(root, tags)
+	if err != nil {
+		return err
+	}
+
+	// metadata is applied after all entries are placed, because placing an entry changes the mtime of the directory
+	for _, tag := range tags {
+		if tag.UsedMetadata {
+			err = fileOp.applyMetadata(root+"/"+tag.Path, tag.Metadata, !noOwnerOption)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 func init() {
 	RootCmd.AddCommand(unstashCmd)
 	unstashCmd.Flags().BoolVarP(&debugOption, "debug", "b", false, "Debug print")
+	unstashCmd.Flags().BoolVarP(&noOwnerOption, "no-owner", "o", false, "Do not restore ownership of files")
 }
@@ -1,9 +1,15 @@
 package commands
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,7 +18,128 @@ type Commit struct {
 	Timestamp int64
 	Hash      Hash
 	Tags      []Tag
-	Depth     int // memo chained commit depth. use in #arciv-commit-extension
+	Depth     int    // memo chained commit depth. use in #arciv-commit-extension
+	Message   string // recorded in .arciv/list/<commit-id>.info. not included in the hash
+	Info      CommitInfo
+}
+
+// CommitInfo is recorded in .arciv/list/<commit-id>.info with the message, and not included in the hash.
+// It is apart from the tag list file, so older versions can read the tag list file, and 'arciv log' shows it without loading tags.
+type CommitInfo struct {
+	Host      string
+	User      string
+	Version   string   // the version of arciv which created the commit
+	Parents   []string // the latest commit id of the repository where the commit was created and merged commits. empty if it is the first
+	Files     int
+	Bytes     int64
+	UsedFiles bool
+	UsedBytes bool
+}
+
+// commitInfoLines returns lines of .arciv/list/<commit-id>.info. It returns nil if the commit has neither the message nor CommitInfo.
+// The message is percent-encoded because it may have newlines.
+func commitInfoLines(commit Commit) []string {
+	var lines []string
+	if commit.Message != "" {
+		lines = append(lines, "message:"+percentEncode(commit.Message, false))
+	}
+	info := commit.Info
+	if info.Host != "" {
+		lines = append(lines, "host:"+escapeField(info.Host))
+	}
+	if info.User != "" {
+		lines = append(lines, "user:"+escapeField(info.User))
+	}
+	if info.Version != "" {
+		lines = append(lines, "version:"+escapeField(info.Version))
+	}
+	if info.UsedFiles {
+		lines = append(lines, "files:"+strconv.Itoa(info.Files))
+	}
+	if info.UsedBytes {
+		lines = append(lines, "bytes:"+strconv.FormatInt(info.Bytes, 10))
+	}
+	for _, parent := range info.Parents {
+		lines = append(lines, "parent:"+parent)
+	}
+	if lines == nil {
+		return nil
+	}
+	return append([]string{"#arciv-commit-info of:" + commit.Id}, lines...)
+}
+
+// strs2commitInfo parses lines of .arciv/list/<commit-id>.info. Unknown lines are ignored.
+func strs2commitInfo(commitId string, lines []string) (message string, info CommitInfo, err error) {
+	if len(lines) == 0 || lines[0] != "#arciv-commit-info of:"+commitId {
+		return "", CommitInfo{}, errors.New("The first line of .arciv/list/" + commitId + ".info must be '#arciv-commit-info of:" + commitId + "'")
+	}
+	for _, line := range lines[1:] {
+		switch {
+		case strings.HasPrefix(line, "message:"):
+			message, err = percentDecode(line[len("message:"):])
+		case strings.HasPrefix(line, "host:"):
+			info.Host, err = unescapeField(line[len("host:"):])
+		case strings.HasPrefix(line, "user:"):
+			info.User, err = unescapeField(line[len("user:"):])
+		case strings.HasPrefix(line, "version:"):
+			info.Version, err = unescapeField(line[len("version:"):])
+		case strings.HasPrefix(line, "files:"):
+			info.Files, err = strconv.Atoi(line[len("files:"):])
+			info.UsedFiles = true
+		case strings.HasPrefix(line, "bytes:"):
+			info.Bytes, err = strconv.ParseInt(line[len("bytes:"):], 10, 64)
+			info.UsedBytes = true
+		case strings.HasPrefix(line, "parent:"):
+			parent := line[len("parent:"):]
+			info.Parents = append(info.Parents, parent)
+			if !isCommitId(parent) {
+				err = errors.New("The line 'parent:' of .arciv/list/" + commitId + ".info must have a commit id")
+			}
+		}
+		if err != nil {
+			return "", CommitInfo{}, err
+		}
+	}
+	return message, info, nil
+}
+
+// describeCommit returns the commit with CommitInfo of this computer and the self repository.
+// merged are commit ids recorded as parents following the latest commit.
+func describeCommit(commit Commit, merged ...string) (Commit, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return Commit{}, err
+	}
+	commit.Info.Host = host
+	if u, err := user.Current(); err == nil {
+		commit.Info.User = u.Username
+	}
+	commit.Info.Version = versionStr
+	commit.Info.Files = len(commit.Tags)
+	commit.Info.UsedFiles = true
+	commit.Info.Bytes, commit.Info.UsedBytes = sizeOfTags(blobTags(commit.Tags), true)
+	timeline, err := SelfRepo().LoadTimeline()
+	if err != nil {
+		return Commit{}, err
+	}
+	if len(timeline) > 0 {
+		latestId := timeline[len(timeline)-1]
+		if latestId == commit.Id {
+			// the same commit is already recorded in the same second
+			commit.Message, commit.Info, err = SelfRepo().loadCommitInfo(latestId)
+			if err != nil {
+				return Commit{}, err
+			}
+			return commit, nil
+		}
+		commit.Info.Parents = []string{latestId}
+	}
+	for _, commitId := range merged {
+		if !isIncluded(commit.Info.Parents, commitId) {
+			commit.Info.Parents = append(commit.Info.Parents, commitId)
+		}
+	}
+	return commit, nil
 }
 
 var runFastlyOption bool
@@ -31,9 +158,17 @@ func createCommitStructure() (c Commit, err error) {
 	if err != nil {
 		return Commit{}, err
 	}
+	dirPaths, err := fileOp.findDirPaths(root)
+	if err != nil {
+		return Commit{}, err
+	}
+	paths = append(paths, emptyDirPaths(paths, dirPaths)...)
+	sort.Strings(paths)
+
 	var tags []Tag
+	hardlinks := make(map[string]Tag) // the first file of each hardlink group
 	for _, path := range paths {
-		tag, err := tagging(root, path, !runFastlyOption)
+		tag, err := tagging(root, path, !runFastlyOption, hardlinks)
 		if err != nil {
 			return Commit{}, err
 		}
@@ -45,31 +180,43 @@ func createCommitStructure() (c Commit, err error) {
 			return Commit{}, err
 		}
 		for i, tag := range tags {
+			if tag.UsedHash || tag.entryType() == TAG_TYPE_HARDLINK {
+				continue
+			}
 			lci := findTagIndex(latestCommit.Tags, tag, FIND_PATH|FIND_TIMESTAMP)
-			if lci != -1 && tag.UsedTimestamp {
+			if lci != -1 && tag.UsedTimestamp && latestCommit.Tags[lci].entryType() == TAG_TYPE_FILE && latestCommit.Tags[lci].Hash.Algorithm().Name == currentHashAlgorithm().Name {
 				// path and timestamp is same as latest commit's one
 				//   hash will be same as latest commit's one (fast mode)
-				tags[i] = latestCommit.Tags[lci]
+				tags[i].Hash = latestCommit.Tags[lci].Hash
+				tags[i].UsedHash = true
 				continue
 			}
-			hash, err := fileOp.hashFile(root + "/" + tag.Path)
+			hash, err := fileOp.hashFile(root + "/" + tag.localPath())
 			if err != nil {
 				return Commit{}, err
 			}
 			tags[i].Hash = hash
 			tags[i].UsedHash = true
 		}
+		// a hardlink has the same hash as the first file of the group
+		for i, tag := range tags {
+			if tag.entryType() == TAG_TYPE_HARDLINK {
+				idx := findTagIndex(tags, Tag{Path: tag.Target}, FIND_PATH)
+				tags[i].Hash = tags[idx].Hash
+				tags[i].UsedHash = true
+			}
+		}
 	}
 	sort.Slice(tags, func(i, j int) bool {
 		return compareTag(tags[i], tags[j]) < 0
 	})
+	err = checkNormalizedPaths(tags)
+	if err != nil {
+		return Commit{}, err
+	}
 
 	// Hash
-	hasher := sha256.New()
-	for _, tag := range tags {
-		fmt.Fprintln(hasher, tag.String())
-	}
-	hash := Hash(hasher.Sum(nil))
+	hash := hashTags(currentHashAlgorithm(), tags)
 	// Timestamp
 	timestamp := timestampNow()
 
@@ -82,18 +229,118 @@ func createCommitStructure() (c Commit, err error) {
 	}, nil
 }
 
-func tagging(root, relativePath string, withHashing bool) (tag Tag, err error) {
+// parseCommitId splits a commit id "<timestamp (8 hex)>-<hash>" to the timestamp and the hash
+func parseCommitId(commitId string) (timestamp int64, hash Hash, err error) {
+	if len(commitId) <= 9 || commitId[8] != '-' {
+		return 0, Hash{}, errors.New("A commit id must be a timestamp and a hash joined by '-'")
+	}
+	timestamp, err = str2timestamp(commitId[:8])
+	if err != nil {
+		return 0, Hash{}, err
+	}
+	hash, err = hex2hash(commitId[9:])
+	if err != nil {
+		return 0, Hash{}, err
+	}
+	return timestamp, hash, nil
+}
+
+func isCommitId(commitId string) bool {
+	_, _, err := parseCommitId(commitId)
+	return err == nil
+}
+
+// hashTags hashes sorted tags. The hash is used in the commit id
+func hashTags(algorithm HashAlgorithm, tags []Tag) Hash {
+	hasher := algorithm.New()
+	for _, tag := range tags {
+		fmt.Fprintln(hasher, tag.hashLine())
+		for _, line := range tag.xattrLines() {
+			fmt.Fprintln(hasher, line)
+		}
+	}
+	return algorithm.Sum(hasher)
+}
+
+func (c Commit) verifyHash() error {
+	if bytes.Compare(hashTags(c.Hash.Algorithm(), c.Tags), c.Hash) != 0 {
+		return errors.New("Tags of the commit " + c.Id + " do not match the commit hash")
+	}
+	return nil
+}
+
+// sizeOfTags sums sizes of tags. Tags sharing a blob are counted once if uniqueBlob is true
+func sizeOfTags(tags []Tag, uniqueBlob bool) (size int64, known bool) {
+	known = true
+	counted := make(map[string]struct{})
+	for _, tag := range tags {
+		if uniqueBlob {
+			if _, ok := counted[tag.Hash.String()]; ok {
+				continue
+			}
+			counted[tag.Hash.String()] = struct{}{}
+		}
+		size += tag.Size
+		known = known && tag.UsedSize
+	}
+	return size, known
+}
+
+// emptyDirPaths returns directories which have no files and no directories
+func emptyDirPaths(filePaths, dirPaths []string) (emptyDirs []string) {
+	parents := make(map[string]struct{})
+	for _, path := range append(append([]string{}, filePaths...), dirPaths...) {
+		parents[filepath.Dir(path)] = struct{}{}
+	}
+	for _, dir := range dirPaths {
+		if _, ok := parents[dir]; !ok {
+			emptyDirs = append(emptyDirs, dir)
+		}
+	}
+	return emptyDirs
+}
+
+// tagging creates a tag of a file, a symbolic link or an empty directory.
+// hardlinks memorizes the first file of each hardlink group, and the following files are tagged as TAG_TYPE_HARDLINK.
+func tagging(root, relativePath string, withHashing bool, hardlinks map[string]Tag) (tag Tag, err error) {
 	path := root + "/" + relativePath
-	// hash
+	entry, err := fileOp.statEntry(path)
+	if err != nil {
+		return Tag{}, err
+	}
 
+	// hash
 	var hash Hash
-	if withHashing {
+	var target string
+	usedHash := withHashing
+	primary, isHardlink := hardlinks[entry.LinkKey]
+	isHardlink = isHardlink && entry.LinkKey != ""
+	switch {
+	case entry.Type == TAG_TYPE_SYMLINK:
+		// a symbolic link is hashed with the target
+		target = entry.Target
+		hash = currentHashAlgorithm().hashBytes([]byte(target))
+		usedHash = true
+	case entry.Type == TAG_TYPE_CHARDEV || entry.Type == TAG_TYPE_BLOCKDEV || entry.Type == TAG_TYPE_NESTED:
+		// a device is hashed with the device number, and a nested repository with the commit id
+		target = entry.Target
+		hash = currentHashAlgorithm().hashBytes([]byte(target))
+		usedHash = true
+	case entry.Type != TAG_TYPE_FILE:
+		// an empty directory, a FIFO or a socket has no content
+		hash = currentHashAlgorithm().hashBytes([]byte{})
+		usedHash = true
+	case isHardlink:
+		entry.Type = TAG_TYPE_HARDLINK
+		target = primary.Path
+		hash = primary.Hash
+	case withHashing:
 		hash, err = fileOp.hashFile(path)
 		if err != nil {
 			return Tag{}, err
 		}
 		if debugOption {
-			message("(sha256) " + hash.String() + " " + path)
+			message("(" + hash.Algorithm().Name + ") " + hash.String() + " " + path)
 		}
 	}
 
@@ -103,11 +350,55 @@ func tagging(root, relativePath string, withHashing bool) (tag Tag, err error) {
 		return Tag{}, err
 	}
 
-	return Tag{
-		Path:          relativePath,
+	// size
+	var size int64
+	if entry.Type == TAG_TYPE_FILE || entry.Type == TAG_TYPE_HARDLINK || entry.Type == TAG_TYPE_SYMLINK {
+		size, err = fileOp.sizeFile(path)
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	// metadata
+	var metadata FileMetadata
+	usedMetadata := recordingMetadata()
+	if usedMetadata {
+		metadata, err = fileOp.metadataFile(path)
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	// extended attributes (a symbolic link is not supported)
+	var xattrs []Xattr
+	usedXattrs := recordingXattrs()
+	if usedXattrs && entry.Type != TAG_TYPE_SYMLINK {
+		xattrs, err = xattrsOf(path)
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	tag = Tag{
+		Path:          normalizePath(relativePath),
 		Hash:          hash,
 		Timestamp:     timestamp,
+		Size:          size,
+		Metadata:      metadata,
+		Type:          entry.Type,
+		Target:        target,
+		Xattrs:        xattrs,
 		UsedTimestamp: true,
-		UsedHash:      withHashing,
-	}, nil
+		UsedHash:      usedHash,
+		UsedSize:      true,
+		UsedMetadata:  usedMetadata,
+		UsedXattrs:    usedXattrs,
+	}
+	if tag.Path != relativePath {
+		tag.FsPath = relativePath
+	}
+	if entry.LinkKey != "" && !isHardlink {
+		hardlinks[entry.LinkKey] = tag
+	}
+	return tag, nil
 }
@@ -53,6 +53,15 @@ func TestCommit(t *testing.T) {
 				panic("fileOp.timestampFile is called with unknown path")
 			}
 		},
+		sizeFile: func(path string) (int64, error) {
+			return int64(len(path)), nil
+		},
+		findDirPaths: func(root string) ([]string, error) {
+			return []string{}, nil
+		},
+		statEntry: func(path string) (FileEntry, error) {
+			return FileEntry{Type: TAG_TYPE_FILE}, nil
+		},
 	}
 
 	// func createCommitStructure(fastly bool) (Commit, error)
@@ -97,9 +106,86 @@ func TestCommit(t *testing.T) {
 		if got.Tags[5].String() != want {
 			t.Errorf("createCommitStructure() return commit, commit.Tags[5].String() = %s, want \"%s\"", got.Tags[5].String(), want)
 		}
+		if got.Tags[0].Size != int64(len("root/path3")) || !got.Tags[0].UsedSize {
+			t.Errorf("createCommitStructure() return commit, commit.Tags[0].Size = %d, want %d", got.Tags[0].Size, len("root/path3"))
+		}
 		// FIXME: Add a test case createCommitStructure() (runFastlyOption = true)
 	})
 
+	t.Run("createCommitStructure() with links, an empty directory and a nested repository", func(t *testing.T) {
+		runFastlyOption = false
+		fileOp.findFilePaths = func(root string) ([]string, error) {
+			return []string{"path0", "path1", "path2", "dir/link", "child"}, nil
+		}
+		fileOp.findDirPaths = func(root string) ([]string, error) {
+			return []string{"dir", "empty"}, nil
+		}
+		fileOp.statEntry = func(path string) (FileEntry, error) {
+			switch path {
+			case "root/path0", "root/path2":
+				return FileEntry{Type: TAG_TYPE_FILE, LinkKey: "1:2"}, nil
+			case "root/dir/link":
+				return FileEntry{Type: TAG_TYPE_SYMLINK, Target: "../path1"}, nil
+			case "root/empty":
+				return FileEntry{Type: TAG_TYPE_DIR}, nil
+			case "root/child":
+				return FileEntry{Type: TAG_TYPE_NESTED, Target: "aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}, nil
+			}
+			return FileEntry{Type: TAG_TYPE_FILE}, nil
+		}
+		fileOp.timestampFile = func(path string) (int64, error) {
+			return 0, nil
+		}
+		got, err := createCommitStructure()
+		if err != nil {
+			t.Errorf("createCommitStructure() return error, %s", err)
+		}
+		if len(got.Tags) != 6 {
+			t.Fatalf("createCommitStructure() return %d tags, want 6", len(got.Tags))
+		}
+		types := make(map[string]Tag)
+		for _, tag := range got.Tags {
+			types[tag.Path] = tag
+		}
+		if tag := types["path2"]; tag.entryType() != TAG_TYPE_HARDLINK || tag.Target != "path0" || tag.Hash.String() != types["path0"].Hash.String() {
+			t.Errorf("createCommitStructure() return a tag of path2 %s (%s, %s), want a hardlink to path0", tag.String(), tag.entryType(), tag.Target)
+		}
+		if tag := types["dir/link"]; tag.entryType() != TAG_TYPE_SYMLINK || tag.Target != "../path1" || tag.hasBlob() {
+			t.Errorf("createCommitStructure() return a tag of dir/link %s (%s, %s), want a symlink to ../path1", tag.String(), tag.entryType(), tag.Target)
+		}
+		if tag := types["empty"]; tag.entryType() != TAG_TYPE_DIR || tag.Size != 0 {
+			t.Errorf("createCommitStructure() return a tag of empty %s (%s), want an empty directory", tag.String(), tag.entryType())
+		}
+		if tag := types["child"]; tag.entryType() != TAG_TYPE_NESTED || tag.hasBlob() || tag.Hash.String() != currentHashAlgorithm().hashBytes([]byte(tag.Target)).String() {
+			t.Errorf("createCommitStructure() return a tag of child %s (%s, %s), want a nested repository", tag.String(), tag.entryType(), tag.Target)
+		}
+		if _, ok := types["dir"]; ok {
+			t.Errorf("createCommitStructure() return a tag of the directory which is not empty")
+		}
+	})
+
+	// func describeCommit(commit Commit, merged ...string) (Commit, error)
+	t.Run("describeCommit() counts a blob of hardlinks once", func(t *testing.T) {
+		fileOp.loadLines = func(path string) ([]string, error) {
+			return []string{}, nil
+		}
+		hash := hashing("a888888888888888888888888888888888888888888888888888888888888883")
+		commit := Commit{Tags: []Tag{
+			Tag{Path: "path0", Hash: hash, Size: 100, UsedSize: true},
+			Tag{Path: "path1", Hash: hash, Size: 100, UsedSize: true, Type: TAG_TYPE_HARDLINK, Target: "path0"},
+			Tag{Path: "link", Hash: hashing("b888888888888888888888888888888888888888888888888888888888888882"), Size: 5, UsedSize: true, Type: TAG_TYPE_SYMLINK, Target: "path0"},
+			Tag{Path: "path2", Hash: hash, Size: 100, UsedSize: true},
+			Tag{Path: "path3", Hash: hashing("c888888888888888888888888888888888888888888888888888888888888881"), Size: 20, UsedSize: true},
+		}}
+		got, err := describeCommit(commit)
+		if err != nil {
+			t.Fatalf("describeCommit() return error, %s", err)
+		}
+		if got.Info.Files != 5 || got.Info.Bytes != 120 || !got.Info.UsedBytes {
+			t.Errorf("describeCommit() return %d files and %d bytes (%v), want 5 files and 120 bytes", got.Info.Files, got.Info.Bytes, got.Info.UsedBytes)
+		}
+	})
+
 	// func tagging(root, relativePath string, withHashing bool) (Tag, error)
 	// tagging() is called in createCommitStructure()
 }
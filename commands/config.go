@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+)
+
+type ConfigKey struct {
+	Name        string
+	Default     string
+	Values      []string // allowed values. any value is allowed if empty
+	Description string
+}
+
+var configKeys = []ConfigKey{
+	ConfigKey{Name: "hash-algorithm", Default: "sha256", Values: []string{"sha256", "sha512", "blake3"}, Description: "Hash algorithm for new commits and blobs"},
+	ConfigKey{Name: "record-metadata", Default: "false", Values: []string{"true", "false"}, Description: "Record permissions, ownership and mtimes of files in new commits"},
+	ConfigKey{Name: "special-files", Default: "skip", Values: []string{"skip", "record", "error"}, Description: "Skip, record or refuse FIFOs, sockets and devices in new commits"},
+	ConfigKey{Name: "one-file-system", Default: "false", Values: []string{"true", "false"}, Description: "Do not cross mount points in the self repository"},
+	ConfigKey{Name: "path-normalization", Default: "none", Values: []string{"none", "nfc", "nfd"}, Description: "Unicode normalization of paths in new commits"},
+	ConfigKey{Name: "nested-repositories", Default: "skip", Values: []string{"skip", "reference"}, Description: "Skip nested repositories or record them with their latest commit ids in new commits"},
+	ConfigKey{Name: "record-xattrs", Default: "false", Values: []string{"true", "false"}, Description: "Record extended attributes and ACLs of files in new commits"},
+	ConfigKey{Name: "restore-confirmation-cost", Default: "1", Description: "Ask for confirmation before sending a restore request estimated to cost more than the USD"},
+}
+
+// configValues is loaded from .arciv/config of the self repository
+var configValues map[string]string
+
+func findConfigKey(name string) (ConfigKey, error) {
+	for _, key := range configKeys {
+		if key.Name == name {
+			return key, nil
+		}
+	}
+	return ConfigKey{}, errors.New("Unknown config key '" + name + "'")
+}
+
+func (key ConfigKey) validate(value string) error {
+	if len(key.Values) == 0 || isIncluded(key.Values, value) {
+		return nil
+	}
+	return errors.New("The value of " + key.Name + " must be one of " + strings.Join(key.Values, ", "))
+}
+
+func configValue(name string) string {
+	if value, ok := configValues[name]; ok {
+		return value
+	}
+	key, err := findConfigKey(name)
+	if err != nil {
+		panic(err)
+	}
+	return key.Default
+}
+
+func loadConfig() {
+	_, err := findRootDir()
+	if err != nil {
+		// not in a repository (ex. arciv init)
+		return
+	}
+	lines, err := fileOp.loadLines(arcivDir() + "/config")
+	if err != nil {
+		Exit(err, 1)
+	}
+	configValues, err = strs2config(lines)
+	if err != nil {
+		Exit(err, 1)
+	}
+}
+
+func strs2config(lines []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, line := range lines {
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			return map[string]string{}, errors.New(".arciv/config is invalid syntax")
+		}
+		key, err := findConfigKey(line[:idx])
+		if err != nil {
+			return map[string]string{}, err
+		}
+		err = key.validate(line[idx+1:])
+		if err != nil {
+			return map[string]string{}, err
+		}
+		values[key.Name] = line[idx+1:]
+	}
+	return values, nil
+}
+
+func config2strs(values map[string]string) (lines []string) {
+	for _, key := range configKeys {
+		if value, ok := values[key.Name]; ok {
+			lines = append(lines, key.Name+":"+value)
+		}
+	}
+	return lines
+}
+
+func writeConfig(values map[string]string) error {
+	return fileOp.writeLines(arcivDir()+"/config", config2strs(values))
+}
+
+func setConfigValue(name, value string) error {
+	key, err := findConfigKey(name)
+	if err != nil {
+		return err
+	}
+	err = key.validate(value)
+	if err != nil {
+		return err
+	}
+	if configValues == nil {
+		configValues = make(map[string]string)
+	}
+	configValues[name] = value
+	return writeConfig(configValues)
+}
@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"strings"
+)
+
+// Relations of two commits, from a commit to another commit
+const (
+	RELATION_SAME         = "same"
+	RELATION_FAST_FORWARD = "fast-forward" // the commit is an ancestor of the other
+	RELATION_BEHIND       = "behind"       // the other is an ancestor of the commit
+	RELATION_DIVERGED     = "diverged"
+)
+
+// parentsFunc returns parent commit ids of a commit
+type parentsFunc func(commitId string) ([]string, error)
+
+// commitParents returns parentsFunc looking up commits in the repositories.
+// A commit created before parents were recorded has the previous commit of the timeline as the parent.
+func commitParents(repositories ...Repository) (parentsFunc, error) {
+	timelines := make([][]string, len(repositories))
+	for i, repository := range repositories {
+		timeline, err := repository.LoadTimeline()
+		if err != nil {
+			return nil, err
+		}
+		timelines[i] = timeline
+	}
+	cache := make(map[string][]string)
+	return func(commitId string) ([]string, error) {
+		if parents, ok := cache[commitId]; ok {
+			return parents, nil
+		}
+		for i, repository := range repositories {
+			idx := indexOf(timelines[i], commitId)
+			if idx == -1 {
+				continue
+			}
+			_, info, err := repository.loadCommitInfo(commitId)
+			if err != nil {
+				return []string{}, err
+			}
+			parents := info.Parents
+			if len(parents) == 0 && idx > 0 && info.Version == "" {
+				parents = []string{timelines[i][idx-1]}
+			}
+			cache[commitId] = parents
+			return parents, nil
+		}
+		return []string{}, nil
+	}, nil
+}
+
+// isAncestor returns true if the ancestor is reached from the commit by following parents.
+// Timestamps are not used to stop following, because a computer whose clock runs behind may create a child older than its parent.
+func isAncestor(ancestorId, commitId string, parentsOf parentsFunc) (bool, error) {
+	visited := map[string]bool{commitId: true}
+	queue := []string{commitId}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == ancestorId {
+			return true, nil
+		}
+		parents, err := parentsOf(id)
+		if err != nil {
+			return false, err
+		}
+		for _, parent := range parents {
+			if visited[parent] {
+				continue
+			}
+			visited[parent] = true
+			queue = append(queue, parent)
+		}
+	}
+	return false, nil
+}
+
+// commitRelation returns the relation from a commit to another commit
+func commitRelation(from, to string, parentsOf parentsFunc) (string, error) {
+	if from == to {
+		return RELATION_SAME, nil
+	}
+	forward, err := isAncestor(from, to, parentsOf)
+	if err != nil || forward {
+		return RELATION_FAST_FORWARD, err
+	}
+	behind, err := isAncestor(to, from, parentsOf)
+	if err != nil || behind {
+		return RELATION_BEHIND, err
+	}
+	return RELATION_DIVERGED, nil
+}
+
+// graphLines returns lines of the timeline from the latest commit with the graph of parents like 'git log --graph'.
+// label returns the text following the graph of a commit. Parents out of the timeline or after the child in it are not drawn.
+func graphLines(timeline []string, parentsOf parentsFunc, label func(commitId string) string) ([]string, error) {
+	var lines []string
+	var columns []string // a commit id expected in each column
+	positions := make(map[string]int)
+	for i, commitId := range timeline {
+		positions[commitId] = i
+	}
+	for i := len(timeline) - 1; i >= 0; i-- {
+		commitId := timeline[i]
+		col := indexOf(columns, commitId)
+		if col == -1 {
+			columns = append(columns, commitId)
+			col = len(columns) - 1
+		}
+		// other columns waiting for the same commit are joined
+		for j := len(columns) - 1; j > col; j-- {
+			if columns[j] == commitId {
+				lines = append(lines, graphJoinLine(len(columns), j))
+				columns = append(columns[:j], columns[j+1:]...)
+			}
+		}
+		marks := make([]string, len(columns))
+		for j := range columns {
+			marks[j] = "|"
+		}
+		marks[col] = "*"
+		lines = append(lines, strings.Join(marks, " ")+" "+label(commitId))
+
+		allParents, err := parentsOf(commitId)
+		if err != nil {
+			return []string{}, err
+		}
+		var parents []string
+		for _, parent := range allParents {
+			if position, ok := positions[parent]; ok && position < i {
+				parents = append(parents, parent)
+			}
+		}
+		if len(parents) == 0 {
+			columns = append(columns[:col], columns[col+1:]...)
+			continue
+		}
+		columns[col] = parents[0]
+		for _, parent := range parents[1:] {
+			if indexOf(columns, parent) != -1 {
+				continue
+			}
+			columns = append(columns, parent)
+			lines = append(lines, graphForkLine(len(columns)))
+		}
+	}
+	return lines, nil
+}
+
+// graphJoinLine returns a line of the graph which the column is joined to the left, and the following columns shift
+func graphJoinLine(width int, joined int) string {
+	marks := make([]string, width)
+	for j := range marks {
+		marks[j] = "|"
+		if j >= joined {
+			marks[j] = "/"
+		}
+	}
+	return strings.Join(marks, " ")
+}
+
+// graphForkLine returns a line of the graph which the last column is forked from the left
+func graphForkLine(width int) string {
+	marks := make([]string, width-1)
+	for j := range marks {
+		marks[j] = "|"
+	}
+	return strings.Join(marks, " ") + " \\"
+}
+
+// latestRelation returns the relation from the latest commit of the repository to the commit.
+// Parents are looked up in the repositories of lookup. The relation is empty if the repository has no commit.
+func latestRelation(repository Repository, commitId string, lookup ...Repository) (latestId string, relation string, err error) {
+	timeline, err := repository.LoadTimeline()
+	if err != nil || len(timeline) == 0 {
+		return "", "", err
+	}
+	latestId = timeline[len(timeline)-1]
+	parentsOf, err := commitParents(lookup...)
+	if err != nil {
+		return "", "", err
+	}
+	relation, err = commitRelation(latestId, commitId, parentsOf)
+	if err != nil {
+		return "", "", err
+	}
+	return latestId, relation, nil
+}
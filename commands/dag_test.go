@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDag(t *testing.T) {
+	id := func(c string) string {
+		return strings.Repeat(c, 8) + "-" + strings.Repeat(c, 64)
+	}
+	// 1 - 2 - 4 - 6
+	//  \- 3 - 5 -/
+	parents := map[string][]string{
+		id("1"): {},
+		id("2"): {id("1")},
+		id("3"): {id("1")},
+		id("4"): {id("2")},
+		id("5"): {id("3")},
+		id("6"): {id("4"), id("5")},
+	}
+	parentsOf := func(commitId string) ([]string, error) {
+		return parents[commitId], nil
+	}
+
+	// func commitRelation(from, to string, parentsOf parentsFunc) (string, error)
+	t.Run("commitRelation()", func(t *testing.T) {
+		cases := []struct {
+			from, to, want string
+		}{
+			{id("2"), id("2"), RELATION_SAME},
+			{id("1"), id("4"), RELATION_FAST_FORWARD},
+			{id("3"), id("6"), RELATION_FAST_FORWARD},
+			{id("6"), id("5"), RELATION_BEHIND},
+			{id("4"), id("5"), RELATION_DIVERGED},
+			{id("2"), id("3"), RELATION_DIVERGED},
+		}
+		for _, c := range cases {
+			got, err := commitRelation(c.from, c.to, parentsOf)
+			if err != nil || got != c.want {
+				t.Errorf("commitRelation(%s, %s) = (%s, %v), want %s", c.from[:1], c.to[:1], got, err, c.want)
+			}
+		}
+
+		// a child older than its parent, created by a computer whose clock runs behind
+		// a - 9 - b
+		skewed := map[string][]string{
+			id("9"): {id("a")},
+			id("b"): {id("9")},
+		}
+		got, err := commitRelation(id("a"), id("b"), func(commitId string) ([]string, error) {
+			return skewed[commitId], nil
+		})
+		if err != nil || got != RELATION_FAST_FORWARD {
+			t.Errorf("commitRelation(a, b) = (%s, %v), want %s", got, err, RELATION_FAST_FORWARD)
+		}
+	})
+
+	// func graphLines(timeline []string, parentsOf parentsFunc, label func(commitId string) string) ([]string, error)
+	t.Run("graphLines()", func(t *testing.T) {
+		// stores from two computers are interleaved in the timeline
+		timeline := []string{id("1"), id("2"), id("3"), id("4"), id("5"), id("6")}
+		got, err := graphLines(timeline, parentsOf, func(commitId string) string {
+			return commitId[:1]
+		})
+		if err != nil {
+			t.Errorf("graphLines() return error \"%s\", want nil", err)
+		}
+		want := []string{
+			"* 6",
+			"| \\",
+			"| * 5",
+			"* | 4",
+			"| * 3",
+			"* | 2",
+			"| /",
+			"* 1",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("graphLines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
@@ -2,33 +2,84 @@ package commands
 
 import (
 	"bufio"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func findPaths(root string, includeFile bool, includeDir bool) (relativePaths []string, err error) {
+	return walkPaths(root, includeFile, includeDir, nil)
+}
+
+// walkPaths finds paths under root except .arciv and paths ignored by .arcivignore.
+// onIgnored is called with ignored paths if it is not nil. Paths in an ignored directory are not walked.
+func walkPaths(root string, includeFile bool, includeDir bool, onIgnored func(IgnoredPath)) (relativePaths []string, err error) {
+	ignorer := &Ignorer{}
+	// the metadata directory may be in the root directory with --arciv-dir
+	separatedArcivDir, err := arcivDirSetting()
+	if err != nil {
+		return []string{}, err
+	}
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		return []string{}, err
+	}
+	rootDevice, _ := deviceOf(rootInfo)
+	specialFiles := configValue("special-files")
 	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		isDir := info.IsDir()
-		if isDir && !includeDir || !isDir && !includeFile {
-			return nil
-		}
 		if len(root) >= len(path) {
 			// exclude root directory
-			return nil
+			return ignorer.load(root, "")
 		}
+		isDir := info.IsDir()
 		relativePath := path[len(root)+1:]
-		if isDir && relativePath == ".arciv" {
+		if isDir && (relativePath == ".arciv" || path == separatedArcivDir) {
+			return filepath.SkipDir
+		}
+		if ignored, pattern := ignorer.ignored(relativePath, isDir); ignored {
+			if onIgnored != nil {
+				onIgnored(IgnoredPath{Path: relativePath, Pattern: pattern})
+			}
+			if isDir {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		if strings.HasPrefix(relativePath, ".arciv/") {
+		if isDir && oneFileSystem() {
+			if device, ok := deviceOf(info); ok && device != rootDevice {
+				warnOnce("skipped a mount point: " + relativePath)
+				return filepath.SkipDir
+			}
+		}
+		if isDir && isRepositoryRoot(path) {
+			if nestedRepositories() == "skip" {
+				warnOnce("skipped a nested repository: " + relativePath)
+				return filepath.SkipDir
+			}
+			// a nested repository is recorded as an entry, and the content is not walked
+			if includeFile {
+				relativePaths = append(relativePaths, relativePath)
+			}
+			return filepath.SkipDir
+		}
+		if isDir {
+			err = ignorer.load(root, relativePath)
+			if err != nil {
+				return err
+			}
+		}
+		if skipped, err := skipSpecialFile(relativePath, info.Mode(), specialFiles); skipped || err != nil {
+			return err
+		}
+		if isDir && !includeDir || !isDir && !includeFile {
 			return nil
 		}
 		// add relative path from root directory
@@ -41,6 +92,129 @@ func findPaths(root string, includeFile bool, includeDir bool) (relativePaths []
 	return relativePaths, nil
 }
 
+// skipSpecialFile returns true if a FIFO, a socket, a device or an irregular file is skipped by the policy of 'special-files',
+// or an error if the policy refuses it
+func skipSpecialFile(relativePath string, mode os.FileMode, specialFiles string) (bool, error) {
+	if mode&os.ModeIrregular != 0 {
+		// no type of tags can record it, so it is skipped even if special files are recorded
+		if specialFiles == "error" {
+			return false, errors.New("'" + relativePath + "' is an irregular file, which can not be recorded")
+		}
+		warnOnce("skipped an irregular file: " + relativePath)
+		return true, nil
+	}
+	if kind := specialFileKind(mode); kind != "" {
+		switch specialFiles {
+		case "skip":
+			warnOnce("skipped a special file: " + relativePath + " (" + kind + ")")
+			return true, nil
+		case "error":
+			return false, errors.New("'" + relativePath + "' is a special file (" + kind + "). Set 'special-files' of 'arciv config' to skip or record special files")
+		}
+	}
+	return false, nil
+}
+
+// specialFileKind returns the tag type of a FIFO, a socket or a device, or an empty string for the others
+func specialFileKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return TAG_TYPE_FIFO
+	case mode&os.ModeSocket != 0:
+		return TAG_TYPE_SOCKET
+	case mode&os.ModeCharDevice != 0:
+		return TAG_TYPE_CHARDEV
+	case mode&os.ModeDevice != 0:
+		return TAG_TYPE_BLOCKDEV
+	}
+	return ""
+}
+
+// fileStat is the status of a file which is available only on some platforms (see file_stat_*.go)
+type fileStat struct {
+	Dev   uint64
+	Ino   uint64
+	Nlink uint64
+	Rdev  uint64 // the device number of a device file
+	Uid   int
+	Gid   int
+}
+
+// errFreeSpaceUnknown is returned by fileOp.freeSpace on a platform which can not get free disk space
+var errFreeSpaceUnknown = errors.New("Free disk space is not available on this platform")
+
+// deviceOf returns the device of a file. It is a variable to be replaced in tests.
+var deviceOf = func(info os.FileInfo) (uint64, bool) {
+	stat, ok := statOf(info)
+	return stat.Dev, ok
+}
+
+var oneFileSystemOption bool
+
+// oneFileSystem returns true if walking does not cross mount points
+func oneFileSystem() bool {
+	return oneFileSystemOption || configValue("one-file-system") == "true"
+}
+
+var warnedMessages = make(map[string]struct{})
+
+// warnOnce prints a message once, even if the tree is walked more than once
+func warnOnce(str string) {
+	if _, ok := warnedMessages[str]; ok {
+		return
+	}
+	warnedMessages[str] = struct{}{}
+	message("warning: " + str)
+}
+
+// readLines reads lines separated by "\n" (or "\r\n") without a limit of the length
+func readLines(r io.Reader) (lines []string, err error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return []string{}, err
+		}
+		if line == "" && err == io.EOF {
+			return lines, nil
+		}
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		lines = append(lines, line)
+		if err == io.EOF {
+			return lines, nil
+		}
+	}
+}
+
+func hashFileWith(algorithm HashAlgorithm, path string) (Hash, error) {
+	hasher := algorithm.New()
+	f, err := os.Open(path)
+	if err != nil {
+		return Hash{}, err
+	}
+	defer f.Close()
+	_, err = io.Copy(hasher, f)
+	if err != nil {
+		return Hash{}, err
+	}
+	return algorithm.Sum(hasher), nil
+}
+
+// size2string formats a number of bytes to be human readable (ex. 1.5GiB)
+func size2string(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(size)
+	i := 0
+	for ; value >= 1024 && i < len(units)-1; i++ {
+		value /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
+
 func message(str string) {
 	fmt.Fprintln(os.Stderr, str)
 }
@@ -51,6 +225,95 @@ func messageStdin(str string) {
 
 var rootDirMemo string
 
+// --work-tree and --arciv-dir separate the files and the metadata directory of the self repository,
+// so a read-only directory can be committed without writing .arciv into it.
+// They are also specified by $ARCIV_WORK_TREE and $ARCIV_DIR.
+var workTreeOption string
+var arcivDirOption string
+
+// workTreeSetting returns the absolute path of --work-tree or $ARCIV_WORK_TREE, or an empty string
+func workTreeSetting() (string, error) {
+	return absoluteSetting(workTreeOption, "ARCIV_WORK_TREE")
+}
+
+// arcivDirSetting returns the absolute path of --arciv-dir or $ARCIV_DIR, or an empty string
+func arcivDirSetting() (string, error) {
+	return absoluteSetting(arcivDirOption, "ARCIV_DIR")
+}
+
+func absoluteSetting(option, env string) (string, error) {
+	if option == "" {
+		option = os.Getenv(env)
+	}
+	if option == "" {
+		return "", nil
+	}
+	return filepath.Abs(option)
+}
+
+// arcivDir returns the metadata directory of the self repository. It is .arciv in the root directory by default.
+func arcivDir() string {
+	dir, err := arcivDirSetting()
+	if err != nil {
+		Exit(err, 1)
+	}
+	if dir != "" {
+		return dir
+	}
+	return fileOp.rootDir() + "/.arciv"
+}
+
+func findRootDir() (string, error) {
+	if rootDirMemo != "" {
+		return rootDirMemo, nil
+	}
+	workTree, err := workTreeSetting()
+	if err != nil {
+		return "", err
+	}
+	dir, err := arcivDirSetting()
+	if err != nil {
+		return "", err
+	}
+	if dir != "" {
+		if f, err := os.Stat(dir); err != nil || !f.IsDir() {
+			return "", errors.New("The arciv directory '" + dir + "' is not found")
+		}
+		if workTree == "" {
+			// the current directory is the root directory like $GIT_DIR of git
+			workTree, err = os.Getwd()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	if workTree != "" {
+		if f, err := os.Stat(workTree); err != nil || !f.IsDir() {
+			return "", errors.New("The work tree '" + workTree + "' is not found")
+		}
+		rootDirMemo = workTree
+		return workTree, nil
+	}
+	// find arciv's root directory (exist .arciv)
+	// ex . current dir is /hoge/fuga/wara
+	// search /hoge/fuga/wara/.arciv , and next /hoge/fuga/.arciv , and next /hoge/.arciv , and next /.arciv
+	currentDir, _ := os.Getwd()
+	for dir := currentDir; strings.LastIndex(dir, "/") != -1; dir = dir[:strings.LastIndex(dir, "/")] {
+		if f, err := os.Stat(dir + "/.arciv"); !os.IsNotExist(err) && f.IsDir() {
+			rootDirMemo = dir
+			return dir, nil
+		}
+	}
+	return "", errors.New(".arciv is not found")
+}
+
+// FileEntry is the type of a path in the self repository
+type FileEntry struct {
+	Type    string
+	Target  string // the target of a symbolic link
+	LinkKey string // the device and the inode of a regular file which has hardlinks
+}
+
 type FileOp struct {
 	copyFile      func(from, to string) error
 	moveFile      func(from, to string) error
@@ -58,11 +321,27 @@ type FileOp struct {
 	mkdirAll      func(path string) error
 	hashFile      func(path string) (Hash, error)
 	timestampFile func(path string) (int64, error)
+	sizeFile      func(path string) (int64, error)
+	freeSpace     func(path string) (int64, error)
+	probeFolding  func(dir string) (FilesystemFolding, error)
+	metadataFile  func(path string) (FileMetadata, error)
+	statEntry     func(path string) (FileEntry, error)
+	symlink       func(target, path string) error
+	link          func(target, path string) error
+	mknod         func(path string, tag Tag) error
+	applyMetadata func(path string, metadata FileMetadata, withOwner bool) error
+	listXattrs    func(path string) ([]Xattr, error)
+	setXattr      func(path string, xattr Xattr) error
 	findFilePaths func(root string) ([]string, error)
 	findDirPaths  func(root string) ([]string, error)
+	findIgnored   func(root string) ([]IgnoredPath, error)
+	nestedCommit  func(dir string) (commitId string, isRepository bool, err error)
 	writeLines    func(path string, lines []string) error
 	loadLines     func(path string) ([]string, error)
 	rootDir       func() string
+	isExist       func(path string) (bool, error)
+	openFile      func(path string) (io.ReadCloser, error)
+	writeFile     func(path string, r io.Reader) error
 }
 
 var fileOp *FileOp
@@ -90,6 +369,27 @@ func init() {
 			return os.Remove(path)
 		},
 
+		openFile: func(path string) (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+
+		// writeFile writes to a temporary file and renames it, so a broken file is not left with the name
+		writeFile: func(path string, r io.Reader) error {
+			w, err := os.Create(path + ".download")
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, r)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path + ".download")
+				return err
+			}
+			return os.Rename(path+".download", path)
+		},
+
 		moveFile: func(from, to string) error {
 			return os.Rename(from, to)
 		},
@@ -99,21 +399,11 @@ func init() {
 		},
 
 		hashFile: func(path string) (Hash, error) {
-			hasher := sha256.New()
-			f, err := os.Open(path)
-			if err != nil {
-				return Hash{}, err
-			}
-			defer f.Close()
-			_, err = io.Copy(hasher, f)
-			if err != nil {
-				return Hash{}, err
-			}
-			return hasher.Sum(nil), nil
+			return hashFileWith(currentHashAlgorithm(), path)
 		},
 
 		timestampFile: func(path string) (int64, error) {
-			fileInfo, err := os.Stat(path)
+			fileInfo, err := os.Lstat(path)
 			if err != nil {
 				return 0, err
 			}
@@ -121,6 +411,135 @@ func init() {
 			return timestamp, nil
 		},
 
+		sizeFile: func(path string) (int64, error) {
+			fileInfo, err := os.Lstat(path)
+			if err != nil {
+				return 0, err
+			}
+			return fileInfo.Size(), nil
+		},
+
+		freeSpace: freeSpace,
+
+		probeFolding: func(dir string) (folding FilesystemFolding, err error) {
+			// create a file named with an upper case letter and a NFC letter, and look it up with other names
+			f, err := os.CreateTemp(dir, "probe-A\u00e9-")
+			if err != nil {
+				return FilesystemFolding{}, err
+			}
+			name := f.Name()
+			f.Close()
+			defer os.Remove(name)
+			base := filepath.Base(name)
+			_, err = os.Lstat(dir + "/" + strings.Replace(base, "A", "a", 1))
+			folding.Case = err == nil
+			_, err = os.Lstat(dir + "/" + strings.Replace(base, "\u00e9", "e\u0301", 1))
+			folding.Normalization = err == nil
+			return folding, nil
+		},
+
+		metadataFile: func(path string) (FileMetadata, error) {
+			fileInfo, err := os.Lstat(path)
+			if err != nil {
+				return FileMetadata{}, err
+			}
+			stat, ok := statOf(fileInfo)
+			if !ok {
+				return FileMetadata{}, errors.New("Ownership of '" + path + "' is not available on this platform")
+			}
+			return FileMetadata{
+				Mode:  fileInfo.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+				Uid:   stat.Uid,
+				Gid:   stat.Gid,
+				User:  lookupUserName(stat.Uid),
+				Group: lookupGroupName(stat.Gid),
+				Mtime: fileInfo.ModTime().UnixNano(),
+			}, nil
+		},
+
+		statEntry: func(path string) (FileEntry, error) {
+			fileInfo, err := os.Lstat(path)
+			if err != nil {
+				return FileEntry{}, err
+			}
+			mode := fileInfo.Mode()
+			switch {
+			case mode&os.ModeSymlink != 0:
+				target, err := os.Readlink(path)
+				if err != nil {
+					return FileEntry{}, err
+				}
+				return FileEntry{Type: TAG_TYPE_SYMLINK, Target: target}, nil
+			case mode.IsDir() && isRepositoryRoot(path):
+				commitId, err := repositoryCommitId(path)
+				if err != nil {
+					return FileEntry{}, err
+				}
+				return FileEntry{Type: TAG_TYPE_NESTED, Target: commitId}, nil
+			case mode.IsDir():
+				return FileEntry{Type: TAG_TYPE_DIR}, nil
+			case specialFileKind(mode) != "":
+				entry := FileEntry{Type: specialFileKind(mode)}
+				if stat, ok := statOf(fileInfo); ok && (entry.Type == TAG_TYPE_CHARDEV || entry.Type == TAG_TYPE_BLOCKDEV) {
+					entry.Target = strconv.FormatUint(stat.Rdev, 10)
+				}
+				return entry, nil
+			case mode.IsRegular():
+				entry := FileEntry{Type: TAG_TYPE_FILE}
+				if stat, ok := statOf(fileInfo); ok && stat.Nlink > 1 {
+					entry.LinkKey = fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+				}
+				return entry, nil
+			}
+			return FileEntry{}, errors.New("'" + path + "' is an unknown type of file")
+		},
+
+		nestedCommit: func(dir string) (string, bool, error) {
+			if !isRepositoryRoot(dir) {
+				return "", false, nil
+			}
+			commitId, err := repositoryCommitId(dir)
+			return commitId, true, err
+		},
+
+		listXattrs: listXattrs,
+
+		setXattr: setXattr,
+
+		symlink: func(target, path string) error {
+			return os.Symlink(target, path)
+		},
+
+		link: func(target, path string) error {
+			return os.Link(target, path)
+		},
+
+		mknod: mknod,
+
+		applyMetadata: func(path string, metadata FileMetadata, withOwner bool) error {
+			fileInfo, err := os.Lstat(path)
+			if err != nil {
+				return err
+			}
+			// chown before chmod, because chown clears setuid and setgid bits
+			if withOwner {
+				uid, gid := metadata.owner()
+				err := os.Lchown(path, uid, gid)
+				if err != nil {
+					return err
+				}
+			}
+			if fileInfo.Mode()&os.ModeSymlink != 0 {
+				// the mode and the mtime of a symbolic link itself cannot be changed portably
+				return nil
+			}
+			err = os.Chmod(path, metadata.Mode)
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(path, time.Now(), metadata.mtime())
+		},
+
 		findFilePaths: func(root string) ([]string, error) {
 			return findPaths(root, true, false)
 		},
@@ -129,39 +548,36 @@ func init() {
 			return findPaths(root, false, true)
 		},
 
+		findIgnored: func(root string) (ignored []IgnoredPath, err error) {
+			_, err = walkPaths(root, false, false, func(i IgnoredPath) {
+				ignored = append(ignored, i)
+			})
+			return ignored, err
+		},
+
 		rootDir: func() string {
-			if rootDirMemo != "" {
-				return rootDirMemo
-			}
-			// find arciv's root directory (exist .arciv)
-			// ex . current dir is /hoge/fuga/wara
-			// search /hoge/fuga/wara/.arciv , and next /hoge/fuga/.arciv , and next /hoge/.arciv , and next /.arciv
-			currentDir, _ := os.Getwd()
-			for dir := currentDir; strings.LastIndex(dir, "/") != -1; dir = dir[:strings.LastIndex(dir, "/")] {
-				if f, err := os.Stat(dir + "/.arciv"); !os.IsNotExist(err) && f.IsDir() {
-					rootDirMemo = dir
-					return dir
-				}
+			dir, err := findRootDir()
+			if err != nil {
+				Exit(err, 1)
+			}
+			return dir
+		},
+
+		isExist: func(path string) (bool, error) {
+			_, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				return false, nil
 			}
-			Exit(errors.New(".arciv is not found"), 1)
-			return ""
+			return err == nil, err
 		},
 
 		loadLines: func(path string) ([]string, error) {
-			var lines []string
 			f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0666)
 			if err != nil {
 				return []string{}, err
 			}
 			defer f.Close()
-			scanner := bufio.NewScanner(f)
-			for scanner.Scan() {
-				lines = append(lines, scanner.Text())
-			}
-			if err := scanner.Err(); err != nil {
-				return []string{}, err
-			}
-			return lines, nil
+			return readLines(f)
 		},
 
 		writeLines: func(path string, lines []string) error {
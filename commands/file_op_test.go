@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFileOp(t *testing.T) {
+	// func specialFileKind(mode os.FileMode) string
+	t.Run("specialFileKind()", func(t *testing.T) {
+		cases := []struct {
+			mode os.FileMode
+			want string
+		}{
+			{0644, ""},
+			{os.ModeDir | 0755, ""},
+			{os.ModeSymlink | 0777, ""},
+			{os.ModeIrregular, ""},
+			{os.ModeNamedPipe | 0644, TAG_TYPE_FIFO},
+			{os.ModeSocket | 0755, TAG_TYPE_SOCKET},
+			{os.ModeDevice | os.ModeCharDevice | 0666, TAG_TYPE_CHARDEV},
+			{os.ModeDevice | 0660, TAG_TYPE_BLOCKDEV},
+		}
+		for _, c := range cases {
+			if got := specialFileKind(c.mode); got != c.want {
+				t.Errorf("specialFileKind(%s) = \"%s\", want \"%s\"", c.mode, got, c.want)
+			}
+		}
+	})
+
+	// func skipSpecialFile(relativePath string, mode os.FileMode, specialFiles string) (bool, error)
+	t.Run("skipSpecialFile()", func(t *testing.T) {
+		cases := []struct {
+			mode         os.FileMode
+			specialFiles string
+			skipped      bool
+			isErr        bool
+		}{
+			{0644, "skip", false, false},
+			{0644, "error", false, false},
+			{os.ModeNamedPipe, "skip", true, false},
+			{os.ModeNamedPipe, "record", false, false},
+			{os.ModeNamedPipe, "error", false, true},
+			{os.ModeDevice, "record", false, false},
+			// irregular files can not be recorded as any type of tags
+			{os.ModeIrregular, "skip", true, false},
+			{os.ModeIrregular, "record", true, false},
+			{os.ModeIrregular, "error", false, true},
+		}
+		for _, c := range cases {
+			skipped, err := skipSpecialFile("a", c.mode, c.specialFiles)
+			if skipped != c.skipped || (err != nil) != c.isErr {
+				t.Errorf("skipSpecialFile(\"a\", %s, \"%s\") = (%v, %v), want (%v, error: %v)", c.mode, c.specialFiles, skipped, err, c.skipped, c.isErr)
+			}
+		}
+	})
+
+	// func walkPaths(root string, includeFile bool, includeDir bool, onIgnored func(IgnoredPath)) (relativePaths []string, err error)
+	t.Run("walkPaths() with one-file-system", func(t *testing.T) {
+		root := t.TempDir()
+		for _, dir := range []string{root + "/local", root + "/mnt"} {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(dir+"/a.txt", []byte("a"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// mnt is on an other device
+		originalDeviceOf := deviceOf
+		deviceOf = func(info os.FileInfo) (uint64, bool) {
+			if info.Name() == "mnt" {
+				return 2, true
+			}
+			return 1, true
+		}
+		defer func() { deviceOf = originalDeviceOf }()
+
+		got, err := findPaths(root, true, false)
+		if err != nil || !reflect.DeepEqual(got, []string{"local/a.txt", "mnt/a.txt"}) {
+			t.Errorf("findPaths() = (%q, %v), want local/a.txt and mnt/a.txt", got, err)
+		}
+
+		oneFileSystemOption = true
+		defer func() { oneFileSystemOption = false }()
+		got, err = findPaths(root, true, false)
+		if err != nil || !reflect.DeepEqual(got, []string{"local/a.txt"}) {
+			t.Errorf("findPaths() with one-file-system = (%q, %v), want local/a.txt", got, err)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package commands
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestWalkSpecialFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(root+"/a.txt", []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(root+"/fifo", 0644); err != nil {
+		t.Skip("a FIFO can not be made: ", err)
+	}
+	defer func() { configValues = nil }()
+
+	cases := []struct {
+		specialFiles string
+		want         []string
+		isErr        bool
+	}{
+		{"skip", []string{"a.txt"}, false},
+		{"record", []string{"a.txt", "fifo"}, false},
+		{"error", []string{}, true},
+	}
+	for _, c := range cases {
+		configValues = map[string]string{"special-files": c.specialFiles}
+		got, err := findPaths(root, true, false)
+		if (err != nil) != c.isErr || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("findPaths() with special-files:%s = (%q, %v), want (%q, error: %v)", c.specialFiles, got, err, c.want, c.isErr)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package commands
+
+import (
+	"errors"
+	"os"
+)
+
+func statOf(info os.FileInfo) (fileStat, bool) {
+	return fileStat{}, false
+}
+
+func freeSpace(path string) (int64, error) {
+	return 0, errFreeSpaceUnknown
+}
+
+func mknod(path string, tag Tag) error {
+	return errors.New("'" + path + "' (" + tag.entryType() + ") cannot be created on this platform")
+}
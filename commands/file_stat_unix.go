@@ -0,0 +1,53 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package commands
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+func statOf(info os.FileInfo) (fileStat, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{}, false
+	}
+	return fileStat{
+		Dev:   uint64(stat.Dev),
+		Ino:   uint64(stat.Ino),
+		Nlink: uint64(stat.Nlink),
+		Rdev:  uint64(stat.Rdev),
+		Uid:   int(stat.Uid),
+		Gid:   int(stat.Gid),
+	}, true
+}
+
+func freeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
+
+func mknod(path string, tag Tag) error {
+	switch tag.entryType() {
+	case TAG_TYPE_FIFO:
+		return syscall.Mkfifo(path, 0666)
+	case TAG_TYPE_CHARDEV, TAG_TYPE_BLOCKDEV:
+		device, err := strconv.ParseUint(tag.Target, 10, 64)
+		if err != nil {
+			return err
+		}
+		mode := uint32(syscall.S_IFCHR)
+		if tag.entryType() == TAG_TYPE_BLOCKDEV {
+			mode = syscall.S_IFBLK
+		}
+		return syscall.Mknod(path, mode|0600, int(device))
+	}
+	return errors.New("'" + path + "' (" + tag.entryType() + ") cannot be created")
+}
@@ -1,15 +1,115 @@
 package commands
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
+	"hash"
+	"lukechampine.com/blake3"
+	"strings"
 )
 
+// Hash is a digest of a file or a commit.
+// A SHA-256 digest is held as it is (backward compatible),
+// and a digest of the other algorithms is prefixed by the algorithm code like multihash.
 type Hash []byte
 
+type HashAlgorithm struct {
+	Name string
+	Code byte
+	Size int
+	New  func() hash.Hash
+}
+
+var (
+	HASH_SHA256 = HashAlgorithm{Name: "sha256", Code: 0x12, Size: sha256.Size, New: sha256.New}
+	HASH_SHA512 = HashAlgorithm{Name: "sha512", Code: 0x13, Size: sha512.Size, New: sha512.New}
+	HASH_BLAKE3 = HashAlgorithm{Name: "blake3", Code: 0x1e, Size: 32, New: func() hash.Hash { return blake3.New(32, nil) }}
+)
+
+var hashAlgorithms = []HashAlgorithm{HASH_SHA256, HASH_SHA512, HASH_BLAKE3}
+
+func findHashAlgorithm(name string) (HashAlgorithm, error) {
+	for _, algorithm := range hashAlgorithms {
+		if algorithm.Name == name {
+			return algorithm, nil
+		}
+	}
+	return HashAlgorithm{}, errors.New("Unknown hash algorithm '" + name + "'")
+}
+
+// currentHashAlgorithm returns the algorithm to hash files and commits newly
+func currentHashAlgorithm() HashAlgorithm {
+	algorithm, err := findHashAlgorithm(configValue("hash-algorithm"))
+	if err != nil {
+		return HASH_SHA256
+	}
+	return algorithm
+}
+
+func (algorithm HashAlgorithm) Sum(hasher hash.Hash) Hash {
+	digest := hasher.Sum(nil)
+	if algorithm.Name == HASH_SHA256.Name {
+		return digest
+	}
+	return append([]byte{algorithm.Code}, digest...)
+}
+
+func (algorithm HashAlgorithm) hashBytes(b []byte) Hash {
+	hasher := algorithm.New()
+	hasher.Write(b)
+	return algorithm.Sum(hasher)
+}
+
+func (hash Hash) Algorithm() HashAlgorithm {
+	if len(hash) == HASH_SHA256.Size {
+		return HASH_SHA256
+	}
+	for _, algorithm := range hashAlgorithms {
+		if len(hash) > 0 && hash[0] == algorithm.Code {
+			return algorithm
+		}
+	}
+	return HashAlgorithm{}
+}
+
+func (hash Hash) digest() []byte {
+	if len(hash) == HASH_SHA256.Size {
+		return hash
+	}
+	return hash[1:]
+}
+
+// String returns the hex string of SHA-256 digest (ex. "0223...93f7"),
+// or the algorithm name and the hex string of the digest (ex. "blake3-af13...0c9e")
 func (hash Hash) String() string {
-	return hex.EncodeToString(hash)
+	algorithm := hash.Algorithm()
+	if algorithm.Name == HASH_SHA256.Name || algorithm.Name == "" {
+		return hex.EncodeToString(hash)
+	}
+	return algorithm.Name + "-" + hex.EncodeToString(hash.digest())
 }
 
 func hex2hash(hexStr string) (Hash, error) {
-	return hex.DecodeString(hexStr)
+	algorithm := HASH_SHA256
+	if idx := strings.Index(hexStr, "-"); idx != -1 {
+		var err error
+		algorithm, err = findHashAlgorithm(hexStr[:idx])
+		if err != nil {
+			return Hash{}, err
+		}
+		hexStr = hexStr[idx+1:]
+	}
+	digest, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return Hash{}, err
+	}
+	if len(digest) != algorithm.Size {
+		return Hash{}, errors.New("Length of a " + algorithm.Name + " hash is invalid")
+	}
+	if algorithm.Name == HASH_SHA256.Name {
+		return digest, nil
+	}
+	return append([]byte{algorithm.Code}, digest...), nil
 }
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	// func hex2hash(hexStr string) (Hash, error)
+	// func (hash Hash) String() string
+	t.Run("hex2hash()", func(t *testing.T) {
+		for _, str := range []string{
+			"0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7",
+			"blake3-0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7",
+			"sha512-0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f70223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7",
+		} {
+			got, err := hex2hash(str)
+			if err != nil {
+				t.Errorf("hex2hash(%s) return an error \"%s\", want nil", str, err)
+				continue
+			}
+			if got.String() != str {
+				t.Errorf("hex2hash(%s).String() = %s", str, got.String())
+			}
+		}
+		for _, str := range []string{
+			"0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193",
+			"md5-0223497a0b8b033aa58a3a521b862986",
+			"blake3-0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f70000",
+			"2222222222222222222222222222222222222222222222222222222222222222.download",
+		} {
+			_, err := hex2hash(str)
+			if err == nil {
+				t.Errorf("hex2hash(%s) return nil, want an error", str)
+			}
+		}
+	})
+
+	// func (hash Hash) Algorithm() HashAlgorithm
+	t.Run("Hash.Algorithm()", func(t *testing.T) {
+		if got := hashing("0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7").Algorithm().Name; got != "sha256" {
+			t.Errorf("Hash.Algorithm() = %s, want sha256", got)
+		}
+		if got := hashing("blake3-0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7").Algorithm().Name; got != "blake3" {
+			t.Errorf("Hash.Algorithm() = %s, want blake3", got)
+		}
+	})
+
+	// func (algorithm HashAlgorithm) Sum(hasher hash.Hash) Hash
+	t.Run("HashAlgorithm.Sum()", func(t *testing.T) {
+		hasher := HASH_BLAKE3.New()
+		hasher.Write([]byte("hello\n"))
+		got := HASH_BLAKE3.Sum(hasher)
+		if got.Algorithm().Name != "blake3" || len(got) != 33 || got[0] != HASH_BLAKE3.Code {
+			t.Errorf("HashAlgorithm.Sum() = %s", got)
+		}
+		hasher = HASH_SHA256.New()
+		hasher.Write([]byte("hello\n"))
+		got = HASH_SHA256.Sum(hasher)
+		if bytes.Compare(got, hashing("5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03")) != 0 {
+			t.Errorf("HashAlgorithm.Sum() = %s", got)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// IgnorePattern is a line of .arcivignore. The syntax is the same as .gitignore.
+type IgnorePattern struct {
+	Base     string // the directory of .arcivignore relative to the root. empty means the root
+	Source   string // ex. "sub/.arcivignore:3"
+	Text     string // the line as it is written
+	Segments []string
+	Negated  bool // "!pattern" re-includes paths
+	DirOnly  bool // "pattern/" matches directories only
+	Anchored bool // a pattern including "/" matches paths relative to Base, otherwise matches names at any depth
+}
+
+type IgnoredPath struct {
+	Path    string
+	Pattern IgnorePattern
+}
+
+func (ignored IgnoredPath) String() string {
+	return ignored.Path + " (" + ignored.Pattern.Source + ": " + ignored.Pattern.Text + ")"
+}
+
+// str2ignorePattern parses a line of .arcivignore. ok is false for blank lines and comments
+func str2ignorePattern(line, base, source string) (pattern IgnorePattern, ok bool) {
+	pattern = IgnorePattern{Base: base, Source: source, Text: line}
+	// trailing spaces are ignored unless they are escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return IgnorePattern{}, false
+	}
+	if strings.HasPrefix(line, "!") {
+		pattern.Negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		pattern.Anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return IgnorePattern{}, false
+	}
+	pattern.Segments = strings.Split(line, "/")
+	return pattern, true
+}
+
+func (pattern IgnorePattern) match(relativePath string, isDir bool) bool {
+	if pattern.DirOnly && !isDir {
+		return false
+	}
+	if pattern.Base != "" {
+		if !strings.HasPrefix(relativePath, pattern.Base+"/") {
+			return false
+		}
+		relativePath = relativePath[len(pattern.Base)+1:]
+	}
+	if !pattern.Anchored {
+		return matchSegments(pattern.Segments, []string{path.Base(relativePath)})
+	}
+	return matchSegments(pattern.Segments, strings.Split(relativePath, "/"))
+}
+
+// matchSegments matches glob segments with path segments. "**" matches zero or more segments.
+func matchSegments(patterns, segments []string) bool {
+	if len(patterns) == 0 {
+		return len(segments) == 0
+	}
+	if patterns[0] == "**" {
+		if len(patterns) == 1 {
+			// "dir/**" matches everything inside dir, but not dir itself
+			return len(segments) > 0
+		}
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(patterns[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(patterns[0], segments[0])
+	return err == nil && matched && matchSegments(patterns[1:], segments[1:])
+}
+
+// Ignorer holds patterns of .arcivignore files loaded while walking the root directory
+type Ignorer struct {
+	patterns []IgnorePattern
+}
+
+func (ignorer *Ignorer) add(lines []string, base string) {
+	name := ".arcivignore"
+	if base != "" {
+		name = base + "/.arcivignore"
+	}
+	for i, line := range lines {
+		if pattern, ok := str2ignorePattern(line, base, name+":"+strconv.Itoa(i+1)); ok {
+			ignorer.patterns = append(ignorer.patterns, pattern)
+		}
+	}
+}
+
+// load reads .arcivignore in the directory dir relative to root if it exists
+func (ignorer *Ignorer) load(root, dir string) error {
+	name := root + "/.arcivignore"
+	if dir != "" {
+		name = root + "/" + dir + "/.arcivignore"
+	}
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines, err := readLines(f)
+	if err != nil {
+		return err
+	}
+	ignorer.add(lines, dir)
+	return nil
+}
+
+// ignored returns true and the pattern if the path is ignored.
+// The last matching pattern decides, so a pattern in a deeper .arcivignore or a later line has priority.
+func (ignorer *Ignorer) ignored(relativePath string, isDir bool) (bool, IgnorePattern) {
+	for i := len(ignorer.patterns) - 1; i >= 0; i-- {
+		pattern := ignorer.patterns[i]
+		if pattern.match(relativePath, isDir) {
+			return !pattern.Negated, pattern
+		}
+	}
+	return false, IgnorePattern{}
+}
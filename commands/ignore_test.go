@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestIgnore(t *testing.T) {
+	// func str2ignorePattern(line, base, source string) (IgnorePattern, bool)
+	t.Run("str2ignorePattern()", func(t *testing.T) {
+		for _, line := range []string{"", "   ", "# comment", "/"} {
+			if _, ok := str2ignorePattern(line, "", ".arcivignore:1"); ok {
+				t.Errorf("str2ignorePattern(%q) return ok, want not ok", line)
+			}
+		}
+		got, ok := str2ignorePattern("!/render/tmp/  ", "sub", "sub/.arcivignore:2")
+		if !ok || !got.Negated || !got.DirOnly || !got.Anchored || len(got.Segments) != 2 || got.Segments[0] != "render" {
+			t.Errorf("str2ignorePattern() = %+v", got)
+		}
+		got, ok = str2ignorePattern("\\#file", "", ".arcivignore:3")
+		if !ok || got.Negated || got.Anchored || got.Segments[0] != "#file" {
+			t.Errorf("str2ignorePattern() = %+v", got)
+		}
+	})
+
+	// func (ignorer *Ignorer) ignored(relativePath string, isDir bool) (bool, IgnorePattern)
+	t.Run("Ignorer.ignored()", func(t *testing.T) {
+		ignorer := &Ignorer{}
+		ignorer.add([]string{
+			".DS_Store",
+			"*.sw[op]",
+			"cache/",
+			"/build",
+			"docs/**/*.tmp",
+			"*.log",
+			"!keep.log",
+		}, "")
+		ignorer.add([]string{
+			"/local.txt",
+			"!*.swp",
+		}, "sub")
+		cases := []struct {
+			path    string
+			isDir   bool
+			ignored bool
+		}{
+			{".DS_Store", false, true},
+			{"photos/2021/.DS_Store", false, true},
+			{"photos/.file.swo", false, true},
+			{"cache", true, true},
+			{"cache", false, false},
+			{"photos/cache", true, true},
+			{"build", true, true},
+			{"src/build", true, false},
+			{"docs/a.tmp", false, true},
+			{"docs/a/b/c.tmp", false, true},
+			{"a.tmp", false, false},
+			{"error.log", false, true},
+			{"keep.log", false, false},
+			{"sub/local.txt", false, true},
+			{"local.txt", false, false},
+			{"sub/deeper/local.txt", false, false},
+			{"sub/a.swp", false, false},
+			{"other/a.swp", false, true},
+		}
+		for _, c := range cases {
+			got, pattern := ignorer.ignored(c.path, c.isDir)
+			if got != c.ignored {
+				t.Errorf("Ignorer.ignored(%s, %t) = %t (%s), want %t", c.path, c.isDir, got, pattern.Source, c.ignored)
+			}
+		}
+		_, pattern := ignorer.ignored("sub/local.txt", false)
+		if pattern.Source != "sub/.arcivignore:1" || pattern.Text != "/local.txt" {
+			t.Errorf("Ignorer.ignored() return the pattern %+v", pattern)
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Label is a name of a commit like a tag of git, recorded in .arciv/labels of each repository
+type Label struct {
+	Name     string
+	CommitId string
+}
+
+func (label Label) Line() string {
+	return label.Name + " " + label.CommitId
+}
+
+func line2label(line string) (Label, error) {
+	elements := strings.Split(line, " ")
+	if len(elements) != 2 || !isLabelName(elements[0]) || !isCommitId(elements[1]) {
+		return Label{}, errors.New("A line of labels must be a label name and a commit id separated by a space")
+	}
+	return Label{Name: elements[0], CommitId: elements[1]}, nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+var commitAliasRegexp = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+// isLabelName returns true if the name can be a label.
+// A name which may be a prefix of a commit id, includes ".." of a commit range or is a keyword of revisions is rejected.
+func isLabelName(name string) bool {
+	return labelNameRegexp.MatchString(name) && !commitAliasRegexp.MatchString(name) && !strings.Contains(name, "..") && name != REVISION_LATEST
+}
+
+// LoadLabels returns labels of the repository sorted by the name. A repository without labels has no .arciv/labels
+// Labels with an invalid signature or an untrusted key are refused.
+func (repository Repository) LoadLabels() ([]Label, error) {
+	labels, _, err := repository.loadLabelRecords()
+	return labels, err
+}
+
+// loadLabelRecords returns labels and removed labels of the repository.
+// Removed labels are recorded in .arciv/labels.removed to remove them in other repositories on copying labels.
+func (repository Repository) loadLabelRecords() (labels []Label, removed []Label, err error) {
+	exist, err := repository.Location.isExist(".arciv/labels")
+	if err != nil || !exist {
+		return []Label{}, []Label{}, err
+	}
+	lines, err := repository.Location.loadLines(".arciv/labels")
+	if err != nil {
+		return []Label{}, []Label{}, err
+	}
+	labels, err = strs2labels(lines, "#arciv-labels")
+	if err != nil {
+		return []Label{}, []Label{}, errors.New(".arciv/labels is invalid syntax. " + err.Error())
+	}
+	var removedLines []string
+	exist, err = repository.Location.isExist(".arciv/labels.removed")
+	if err == nil && exist {
+		removedLines, err = repository.Location.loadLines(".arciv/labels.removed")
+	}
+	if err != nil {
+		return []Label{}, []Label{}, err
+	}
+	if removedLines != nil {
+		removed, err = strs2labels(removedLines, "#arciv-removed-labels")
+		if err != nil {
+			return []Label{}, []Label{}, errors.New(".arciv/labels.removed is invalid syntax. " + err.Error())
+		}
+	}
+	status, err := repository.verifySignature(".arciv/labels", labelsSignatureMessage(lines, removedLines))
+	if err != nil {
+		return []Label{}, []Label{}, err
+	}
+	err = judgeSignature("The label file of the repository "+repository.Name, status, true)
+	if err != nil {
+		return []Label{}, []Label{}, err
+	}
+	return labels, removed, nil
+}
+
+func strs2labels(lines []string, header string) ([]Label, error) {
+	if len(lines) == 0 || lines[0] != header {
+		return []Label{}, errors.New("The first line must be '" + header + "'")
+	}
+	labels := []Label{}
+	for _, line := range lines[1:] {
+		label, err := line2label(line)
+		if err != nil {
+			return []Label{}, err
+		}
+		labels = append(labels, label)
+	}
+	sortLabels(labels)
+	return labels, nil
+}
+
+func labels2strs(labels []Label, header string) []string {
+	sortLabels(labels)
+	lines := []string{header}
+	for _, label := range labels {
+		lines = append(lines, label.Line())
+	}
+	return lines
+}
+
+// WriteLabels writes labels and removed labels, and signs them together
+func (repository Repository) WriteLabels(labels []Label, removed []Label) error {
+	lines := labels2strs(labels, "#arciv-labels")
+	err := repository.Location.writeLines(".arciv/labels", lines)
+	if err != nil {
+		return err
+	}
+	var removedLines []string
+	if len(removed) > 0 {
+		removedLines = labels2strs(removed, "#arciv-removed-labels")
+		err = repository.Location.writeLines(".arciv/labels.removed", removedLines)
+	} else if exist, _ := repository.Location.isExist(".arciv/labels.removed"); exist {
+		err = repository.Location.removeFile(".arciv/labels.removed")
+	}
+	if err != nil {
+		return err
+	}
+	return repository.writeSignature(".arciv/labels", labelsSignatureMessage(lines, removedLines))
+}
+
+func sortLabels(labels []Label) {
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+}
+
+func findLabelIndex(labels []Label, name string) int {
+	for i, label := range labels {
+		if label.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// setLabel returns labels with the label. A label of the same name is replaced
+func setLabel(labels []Label, label Label) []Label {
+	idx := findLabelIndex(labels, label.Name)
+	if idx == -1 {
+		return append(labels, label)
+	}
+	labels[idx] = label
+	return labels
+}
+
+// resolveLabel returns the commit id if the alias is a label name, otherwise returns the alias as it is
+func resolveLabel(alias string, labels []Label) string {
+	idx := findLabelIndex(labels, alias)
+	if idx == -1 {
+		return alias
+	}
+	return labels[idx].CommitId
+}
+
+// removeLabel returns labels without the label, and removed labels with it
+func removeLabel(labels []Label, removed []Label, idx int) ([]Label, []Label) {
+	removed = append(forgetRemovedLabels(removed, labels[idx].Name), labels[idx])
+	return append(labels[:idx], labels[idx+1:]...), removed
+}
+
+// forgetRemovedLabels returns removed labels except the name, because a label of the name is added again
+func forgetRemovedLabels(removed []Label, name string) []Label {
+	var kept []Label
+	for _, label := range removed {
+		if label.Name != name {
+			kept = append(kept, label)
+		}
+	}
+	return kept
+}
+
+// pushLabels copies labels of the self repository to the repository
+func pushLabels(repository Repository) error {
+	return copyLabels(SelfRepo(), repository)
+}
+
+// copyLabels copies labels of a repository to the repository, if the repository has the labeled commits.
+// A label of the same name in the repository is overwritten.
+// A label removed in the repository copied from is also removed, if the repository has it for the same commit.
+// Other labels which only the repository has are kept.
+func copyLabels(from Repository, repository Repository) error {
+	fromLabels, fromRemoved, err := from.loadLabelRecords()
+	if err != nil {
+		return err
+	}
+	timeline, err := repository.LoadTimeline()
+	if err != nil {
+		return err
+	}
+	labels, removed, err := repository.loadLabelRecords()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, label := range fromRemoved {
+		if findLabelIndex(fromLabels, label.Name) != -1 || !isIncluded(timeline, label.CommitId) {
+			continue
+		}
+		idx := findLabelIndex(labels, label.Name)
+		if idx != -1 && labels[idx] == label {
+			labels, removed = removeLabel(labels, removed, idx)
+			changed = true
+			message("removed the label '" + label.Name + "' of '" + label.CommitId + "' in the repository " + repository.Name)
+			continue
+		}
+		if idx == -1 && findLabelIndex(removed, label.Name) == -1 {
+			// the removal is recorded to be copied to other repositories from the repository
+			removed = append(removed, label)
+			changed = true
+		}
+	}
+	for _, label := range fromLabels {
+		if !isIncluded(timeline, label.CommitId) {
+			continue
+		}
+		idx := findLabelIndex(labels, label.Name)
+		if idx != -1 && labels[idx] == label {
+			continue
+		}
+		labels = setLabel(labels, label)
+		removed = forgetRemovedLabels(removed, label.Name)
+		changed = true
+		message("labeled '" + label.CommitId + "' as '" + label.Name + "' in the repository " + repository.Name)
+	}
+	if !changed {
+		return nil
+	}
+	return repository.WriteLabels(labels, removed)
+}
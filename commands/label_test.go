@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"reflect"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	// func isLabelName(name string) bool
+	t.Run("isLabelName()", func(t *testing.T) {
+		cases := map[string]bool{
+			"before-reorg": true,
+			"v1.0":         true,
+			"2021_photos":  true,
+			"latest":       false,
+			"abc":          false, // a prefix of a commit hash
+			"00001234-ab":  false,
+			"a..b":         false,
+			"-a":           false,
+			"has space":    false,
+			"":             false,
+		}
+		for name, want := range cases {
+			if got := isLabelName(name); got != want {
+				t.Errorf("isLabelName(%q) = %v, want %v", name, got, want)
+			}
+		}
+	})
+
+	// func (repository Repository) WriteLabels(labels []Label, removed []Label) error
+	// func (repository Repository) LoadLabels() ([]Label, error)
+	t.Run("Repository.WriteLabels() and Repository.LoadLabels()", func(t *testing.T) {
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+		}
+		repo := Repository{Name: "repo_name", Location: RepositoryLocationFile{Path: "root"}}
+		labels, err := repo.LoadLabels()
+		if err != nil || len(labels) != 0 {
+			t.Errorf("Repository.LoadLabels() = (%v, %v), want no labels", labels, err)
+		}
+
+		labels = setLabel(labels, Label{Name: "z-last", CommitId: "11111111-1111111111111111111111111111111111111111111111111111111111111111"})
+		labels = setLabel(labels, Label{Name: "before-reorg", CommitId: "00000000-0000000000000000000000000000000000000000000000000000000000000000"})
+		labels = setLabel(labels, Label{Name: "z-last", CommitId: "22222222-2222222222222222222222222222222222222222222222222222222222222222"})
+		err = repo.WriteLabels(labels, []Label{})
+		if err != nil {
+			t.Errorf("Repository.WriteLabels() return error \"%s\", want nil", err)
+		}
+		lines := files["root/.arciv/labels"]
+		if len(lines) != 3 || lines[0] != "#arciv-labels" || lines[1] != "before-reorg 00000000-0000000000000000000000000000000000000000000000000000000000000000" {
+			t.Errorf("Repository.WriteLabels() writes lines %q", lines)
+		}
+		got, err := repo.LoadLabels()
+		if err != nil || len(got) != 2 || got[1].Name != "z-last" || got[1].CommitId[:8] != "22222222" {
+			t.Errorf("Repository.LoadLabels() = (%v, %v)", got, err)
+		}
+		if id := resolveLabel("before-reorg", got); id != "00000000-0000000000000000000000000000000000000000000000000000000000000000" {
+			t.Errorf("resolveLabel(before-reorg) = %s", id)
+		}
+		if id := resolveLabel("1111", got); id != "1111" {
+			t.Errorf("resolveLabel(1111) = %s, want 1111", id)
+		}
+	})
+
+	// func (repository Repository) LoadLabels() ([]Label, error)
+	//   verify the signature of labels
+	t.Run("Repository.LoadLabels() with a signature", func(t *testing.T) {
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			rootDir: func() string { return "local_root" },
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+			removeFile: func(path string) error {
+				delete(files, path)
+				return nil
+			},
+		}
+		signingKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+		defer func() { signingKey = nil }()
+		repo := Repository{Name: "repo_name", Location: RepositoryLocationFile{Path: "root"}}
+		labels := []Label{Label{Name: "before-reorg", CommitId: "00000000-0000000000000000000000000000000000000000000000000000000000000000"}}
+		removed := []Label{Label{Name: "old", CommitId: "11111111-1111111111111111111111111111111111111111111111111111111111111111"}}
+		err := repo.WriteLabels(labels, removed)
+		if err != nil {
+			t.Fatalf("Repository.WriteLabels() return error \"%s\", want nil", err)
+		}
+		if _, ok := files["root/.arciv/labels.sig"]; !ok {
+			t.Errorf("Repository.WriteLabels() does not sign labels")
+		}
+		got, err := repo.LoadLabels()
+		if err != nil || len(got) != 1 {
+			t.Errorf("Repository.LoadLabels() = (%v, %v), want a label", got, err)
+		}
+
+		// tampered
+		files["root/.arciv/labels"] = append(files["root/.arciv/labels"], "after-reorg 00000000-0000000000000000000000000000000000000000000000000000000000000000")
+		if _, err = repo.LoadLabels(); err == nil {
+			t.Errorf("Repository.LoadLabels() of tampered labels return nil, want an error")
+		}
+		files["root/.arciv/labels"] = files["root/.arciv/labels"][:2]
+		delete(files, "root/.arciv/labels.removed")
+		if _, err = repo.LoadLabels(); err == nil {
+			t.Errorf("Repository.LoadLabels() of labels whose removed labels are deleted return nil, want an error")
+		}
+	})
+
+	// func copyLabels(from Repository, repository Repository) error
+	t.Run("copyLabels()", func(t *testing.T) {
+		commitIds := []string{
+			"00000000-0000000000000000000000000000000000000000000000000000000000000000",
+			"11111111-1111111111111111111111111111111111111111111111111111111111111111",
+			"22222222-2222222222222222222222222222222222222222222222222222222222222222",
+		}
+		files := map[string][]string{
+			"from/.arciv/timeline": commitIds,
+			"from/.arciv/labels":   []string{"#arciv-labels", "kept " + commitIds[0]},
+			"from/.arciv/labels.removed": []string{
+				"#arciv-removed-labels",
+				"never-copied " + commitIds[0],
+				"removed " + commitIds[1],
+				"moved " + commitIds[1],
+			},
+			"to/.arciv/timeline": commitIds,
+			"to/.arciv/labels": []string{
+				"#arciv-labels",
+				"moved " + commitIds[2],
+				"only-to " + commitIds[1],
+				"removed " + commitIds[1],
+			},
+		}
+		fileOp = &FileOp{
+			rootDir: func() string { return "local_root" },
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+			removeFile: func(path string) error {
+				delete(files, path)
+				return nil
+			},
+		}
+		from := Repository{Name: "from", Location: RepositoryLocationFile{Path: "from"}}
+		to := Repository{Name: "to", Location: RepositoryLocationFile{Path: "to"}}
+		err := copyLabels(from, to)
+		if err != nil {
+			t.Fatalf("copyLabels() return error \"%s\", want nil", err)
+		}
+		labels, removed, err := to.loadLabelRecords()
+		if err != nil {
+			t.Fatalf("Repository.loadLabelRecords() return error \"%s\", want nil", err)
+		}
+		want := []Label{
+			Label{Name: "kept", CommitId: commitIds[0]},
+			Label{Name: "moved", CommitId: commitIds[2]}, // moved to another commit in the repository
+			Label{Name: "only-to", CommitId: commitIds[1]},
+		}
+		if !reflect.DeepEqual(labels, want) {
+			t.Errorf("copyLabels() writes labels %v, want %v", labels, want)
+		}
+		wantRemoved := []Label{
+			Label{Name: "never-copied", CommitId: commitIds[0]},
+			Label{Name: "removed", CommitId: commitIds[1]},
+		}
+		if !reflect.DeepEqual(removed, wantRemoved) {
+			t.Errorf("copyLabels() writes removed labels %v, want %v", removed, wantRemoved)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+)
+
+// FileMetadata is POSIX metadata of a file recorded with a tag if 'record-metadata' of 'arciv config' is true
+type FileMetadata struct {
+	Mode  os.FileMode // permission bits, setuid, setgid and sticky
+	Uid   int
+	Gid   int
+	User  string // empty if the name of the uid is unknown
+	Group string // empty if the name of the gid is unknown
+	Mtime int64  // nanoseconds since the unix epoch
+}
+
+var TAG_FIELDS_METADATA = []string{"mode", "uid", "gid", "user", "group", "mtime"}
+
+var noOwnerOption bool
+
+func recordingMetadata() bool {
+	return configValue("record-metadata") == "true"
+}
+
+// mode2string formats a file mode to the octal string of unix (ex. "0755", "4755")
+func mode2string(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+func str2mode(str string) (os.FileMode, error) {
+	bits, err := strconv.ParseUint(str, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	if bits > 07777 {
+		return 0, errors.New("A file mode must be 4 octal digits or less")
+	}
+	mode := os.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// name2string and str2name write an unknown user or group name as "-"
+func name2string(name string) string {
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+func str2name(str string) string {
+	if str == "-" {
+		return ""
+	}
+	return str
+}
+
+var userNameMemo = make(map[int]string)
+var groupNameMemo = make(map[int]string)
+
+func lookupUserName(uid int) string {
+	if name, ok := userNameMemo[uid]; ok {
+		return name
+	}
+	name := ""
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		name = u.Username
+	}
+	userNameMemo[uid] = name
+	return name
+}
+
+func lookupGroupName(gid int) string {
+	if name, ok := groupNameMemo[gid]; ok {
+		return name
+	}
+	name := ""
+	if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+		name = g.Name
+	}
+	groupNameMemo[gid] = name
+	return name
+}
+
+// owner returns the uid and the gid to restore.
+// The names are preferred to the ids if the names exist on this computer.
+func (metadata FileMetadata) owner() (uid, gid int) {
+	uid, gid = metadata.Uid, metadata.Gid
+	if metadata.User != "" {
+		if u, err := user.Lookup(metadata.User); err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil {
+				uid = id
+			}
+		}
+	}
+	if metadata.Group != "" {
+		if g, err := user.LookupGroup(metadata.Group); err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil {
+				gid = id
+			}
+		}
+	}
+	return uid, gid
+}
+
+func (metadata FileMetadata) mtime() time.Time {
+	return time.Unix(0, metadata.Mtime)
+}
+
+// checkOwnership returns an error if ownership of tags cannot be restored without root privileges
+func checkOwnership(tags []Tag) error {
+	if noOwnerOption || os.Geteuid() == 0 {
+		return nil
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return err
+	}
+	groups = append(groups, os.Getegid())
+	for _, tag := range tags {
+		if !tag.UsedMetadata {
+			continue
+		}
+		uid, gid := tag.Metadata.owner()
+		if uid == os.Geteuid() && isIncludedInt(groups, gid) {
+			continue
+		}
+		return errors.New("Restoring the ownership of '" + tag.Path + "' needs root privileges. Run as root or with --no-owner")
+	}
+	return nil
+}
+
+func isIncludedInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
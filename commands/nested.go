@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"os"
+	"strings"
+)
+
+// nestedRepositories returns 'nested-repositories' of 'arciv config'.
+// "skip" does not commit a nested repository, and "reference" records it as a tag of TAG_TYPE_NESTED
+// with the latest commit id of it like a submodule of git.
+func nestedRepositories() string {
+	return configValue("nested-repositories")
+}
+
+// isRepositoryRoot returns true if dir has .arciv directory
+func isRepositoryRoot(dir string) bool {
+	info, err := os.Lstat(dir + "/.arciv")
+	return err == nil && info.IsDir()
+}
+
+// repositoryCommitId returns the latest commit id of the repository in dir.
+// It does not create .arciv/timeline unlike fileOp.loadLines, and returns an empty string if no commit exists.
+func repositoryCommitId(dir string) (string, error) {
+	f, err := os.Open(dir + "/.arciv/timeline")
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	lines, err := readLines(f)
+	if err != nil || len(lines) == 0 {
+		return "", err
+	}
+	return lines[len(lines)-1], nil
+}
+
+// inRepositoryMetadata returns true if the path is in .arciv of a nested repository.
+// Commits created before nested repositories were detected may include them.
+func inRepositoryMetadata(path string) bool {
+	for _, element := range strings.Split(path, "/") {
+		if element == ".arciv" {
+			return true
+		}
+	}
+	return false
+}
+
+// placeRepository checks a nested repository referred by the tag. Files of it are restored in itself.
+func placeRepository(root string, tag Tag) error {
+	dir := root + "/" + tag.Path
+	commitId, isRepository, err := fileOp.nestedCommit(dir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !isRepository:
+		message("warning: the nested repository " + dir + " is not restored. Restore the commit " + tag.Target + " in it")
+	case commitId != tag.Target:
+		message("warning: the nested repository " + dir + " is at the commit " + commitId + ", not " + tag.Target)
+	}
+	return nil
+}
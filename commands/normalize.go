@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"errors"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pathNormalization returns 'path-normalization' of 'arciv config'; "none", "nfc" or "nfd".
+// macOS writes file names in NFD and Linux usually in NFC, so a tree moved between them needs one form.
+func pathNormalization() string {
+	return configValue("path-normalization")
+}
+
+func normalizePath(path string) string {
+	switch pathNormalization() {
+	case "nfc":
+		return norm.NFC.String(path)
+	case "nfd":
+		return norm.NFD.String(path)
+	}
+	return path
+}
+
+// normalizeTags returns tags whose paths and hardlink targets are normalized with 'path-normalization'.
+// Tags loaded from old commits are compared with new commits after normalization.
+func normalizeTags(tags []Tag) []Tag {
+	normalized := make([]Tag, len(tags))
+	for i, tag := range tags {
+		tag.Path = normalizePath(tag.Path)
+		if tag.entryType() == TAG_TYPE_HARDLINK {
+			tag.Target = normalizePath(tag.Target)
+		}
+		normalized[i] = tag
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		return compareTag(normalized[i], normalized[j]) < 0
+	})
+	return normalized
+}
+
+// localPath returns the path of the tag on the local disk
+func (tag Tag) localPath() string {
+	if tag.FsPath != "" {
+		return tag.FsPath
+	}
+	return tag.Path
+}
+
+// checkNormalizedPaths returns an error if different files on the disk have the same normalized path
+func checkNormalizedPaths(tags []Tag) error {
+	found := make(map[string]Tag)
+	for _, tag := range tags {
+		if other, ok := found[tag.Path]; ok {
+			return errors.New("'" + other.localPath() + "' and '" + tag.localPath() + "' have the same path '" + tag.Path + "' after normalization")
+		}
+		found[tag.Path] = tag
+	}
+	return nil
+}
+
+// FilesystemFolding is how a filesystem compares file names
+type FilesystemFolding struct {
+	Case          bool // "Foo.jpg" and "foo.jpg" are the same file (ex. APFS and NTFS by default)
+	Normalization bool // NFC and NFD names are the same file (ex. APFS and HFS+)
+}
+
+func (folding FilesystemFolding) fold(path string) string {
+	if folding.Normalization {
+		path = norm.NFC.String(path)
+	}
+	if folding.Case {
+		path = cases.Fold().String(path)
+	}
+	return path
+}
+
+// pathCollisions returns groups of paths which are the same file or directory on a filesystem with the folding.
+// Each group is sorted, and groups are sorted by the first path.
+func pathCollisions(tags []Tag, folding FilesystemFolding) (collisions [][]string) {
+	if !folding.Case && !folding.Normalization {
+		return nil
+	}
+	// a path and its parent directories are compared, because "Dir/a" and "dir/b" are merged into one directory
+	found := make(map[string]map[string]struct{})
+	for _, tag := range tags {
+		elements := strings.Split(tag.Path, "/")
+		for i := range elements {
+			path := strings.Join(elements[:i+1], "/")
+			folded := folding.fold(path)
+			if found[folded] == nil {
+				found[folded] = make(map[string]struct{})
+			}
+			found[folded][path] = struct{}{}
+		}
+	}
+	for _, paths := range found {
+		if len(paths) < 2 {
+			continue
+		}
+		var group []string
+		for path := range paths {
+			group = append(group, path)
+		}
+		sort.Strings(group)
+		if dir := filepath.Dir(group[0]); dir != "." && len(found[folding.fold(dir)]) > 1 {
+			// reported as the collision of the parent directory
+			continue
+		}
+		collisions = append(collisions, group)
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i][0] < collisions[j][0]
+	})
+	return collisions
+}
+
+// checkPathCollisions returns an error if paths of tags collide on the filesystem of the self repository
+func checkPathCollisions(tags []Tag) error {
+	folding, err := fileOp.probeFolding(fileOp.rootDir())
+	if err != nil {
+		return err
+	}
+	collisions := pathCollisions(tags, folding)
+	if len(collisions) == 0 {
+		return nil
+	}
+	for _, group := range collisions {
+		message("collision: " + strings.Join(group, ", "))
+	}
+	return errors.New("Some paths of the commit are the same file on the filesystem of the self repository")
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	nfc := "caf\u00e9.jpg"
+	nfd := "cafe\u0301.jpg"
+
+	// func normalizeTags(tags []Tag) []Tag
+	t.Run("normalizeTags()", func(t *testing.T) {
+		configValues = map[string]string{"path-normalization": "nfc"}
+		defer func() { configValues = nil }()
+		tags := []Tag{
+			Tag{Path: "a.jpg", Hash: hashing("1111111111111111111111111111111111111111111111111111111111111111")},
+			Tag{Path: "photos/" + nfd, Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000")},
+			Tag{Path: "z.jpg", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"), Type: TAG_TYPE_HARDLINK, Target: "photos/" + nfd},
+		}
+		got := normalizeTags(tags)
+		// sorted by the hash
+		if len(got) != 3 || got[0].Path != "photos/"+nfc || got[1].Target != "photos/"+nfc {
+			t.Errorf("normalizeTags() = %q", got)
+		}
+		if tags[1].Path != "photos/"+nfd {
+			t.Errorf("normalizeTags() changes the argument")
+		}
+	})
+
+	// func checkNormalizedPaths(tags []Tag) error
+	t.Run("checkNormalizedPaths()", func(t *testing.T) {
+		tags := []Tag{Tag{Path: nfc, FsPath: nfd}, Tag{Path: "a.jpg"}, Tag{Path: nfc}}
+		err := checkNormalizedPaths(tags)
+		if err == nil {
+			t.Errorf("checkNormalizedPaths() return nil, want an error")
+		}
+		err = checkNormalizedPaths(tags[:1])
+		if err != nil {
+			t.Errorf("checkNormalizedPaths() return an error \"%s\", want nil", err)
+		}
+	})
+
+	// func pathCollisions(tags []Tag, folding FilesystemFolding) [][]string
+	t.Run("pathCollisions()", func(t *testing.T) {
+		tags := []Tag{
+			Tag{Path: "Foo.jpg"},
+			Tag{Path: "foo.jpg"},
+			Tag{Path: "Photos/a.jpg"},
+			Tag{Path: "photos/b.jpg"},
+			Tag{Path: "photos/" + nfc},
+			Tag{Path: "photos/" + nfd},
+			Tag{Path: "unique.jpg"},
+		}
+		cases := []struct {
+			folding FilesystemFolding
+			want    []string
+		}{
+			{FilesystemFolding{}, []string{}},
+			{FilesystemFolding{Case: true}, []string{"Foo.jpg,foo.jpg", "Photos,photos"}},
+			{FilesystemFolding{Normalization: true}, []string{"photos/" + nfd + ",photos/" + nfc}},
+			{FilesystemFolding{Case: true, Normalization: true}, []string{"Foo.jpg,foo.jpg", "Photos,photos"}},
+		}
+		for _, c := range cases {
+			var got []string
+			for _, group := range pathCollisions(tags, c.folding) {
+				got = append(got, strings.Join(group, ","))
+			}
+			if strings.Join(got, " ") != strings.Join(c.want, " ") {
+				t.Errorf("pathCollisions(%+v) = %q, want %q", c.folding, got, c.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+	"time"
+)
+
+// verifyingReader hashes the content while it is read, and returns an error instead of io.EOF if the hash is not expected.
+// A writer stopped with the error does not leave the content (ex. a temporary file is removed, a multipart upload is aborted).
+type verifyingReader struct {
+	reader io.Reader
+	hasher hash.Hash
+	want   Hash
+	size   int64
+}
+
+func newVerifyingReader(r io.Reader, want Hash) *verifyingReader {
+	return &verifyingReader{reader: r, hasher: want.Algorithm().New(), want: want}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.reader.Read(p)
+	v.hasher.Write(p[:n])
+	v.size += int64(n)
+	if err == io.EOF && !bytes.Equal(v.want.Algorithm().Sum(v.hasher), v.want) {
+		return n, errors.New("The content of the blob " + v.want.String() + " does not match the hash")
+	}
+	return n, err
+}
+
+// replicateBlob streams a blob from a repository to another repository without a local copy
+func replicateBlob(from, to Repository, blob Hash, storageClass string) (int64, error) {
+	if blob.Algorithm().Name == "" {
+		return 0, errors.New("The hash algorithm of the blob " + blob.String() + " is unknown")
+	}
+	r, err := from.Location.openBlob(blob.String())
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	verifying := newVerifyingReader(r, blob)
+	err = to.Location.writeBlob(blob.String(), verifying, storageClass)
+	if err != nil {
+		return 0, err
+	}
+	return verifying.size, nil
+}
+
+// replicateBlobs streams blobs of tags which the destination does not have.
+// A blob is written with its name only after the whole content is verified, so replicating can be resumed after an interruption.
+// Storage classes are chosen by the storage policy of the destination like 'arciv store'.
+func replicateBlobs(from, to Repository, tags []Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	rules, err := loadStoragePolicy(to.Location)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	if size, known := sizeOfTags(tags, true); known {
+		message("replicating " + strconv.Itoa(len(tags)) + " files, " + size2string(size))
+	}
+	for _, tag := range tags {
+		size, err := replicateBlob(from, to, tag.Hash, storageClassOf(rules, tag, now))
+		if err != nil {
+			return err
+		}
+		message("replicated: " + tag.Hash.String() + ", " + size2string(size))
+	}
+	return nil
+}
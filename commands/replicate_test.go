@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestReplicate(t *testing.T) {
+	// func newVerifyingReader(r io.Reader, want Hash) *verifyingReader
+	t.Run("verifyingReader", func(t *testing.T) {
+		for _, algorithm := range hashAlgorithms {
+			want := algorithm.hashBytes([]byte("hello\n"))
+			got, err := ioutil.ReadAll(newVerifyingReader(strings.NewReader("hello\n"), want))
+			if err != nil || string(got) != "hello\n" {
+				t.Errorf("verifyingReader (%s) reads (%q, %v), want (\"hello\\n\", nil)", algorithm.Name, got, err)
+			}
+			_, err = ioutil.ReadAll(newVerifyingReader(strings.NewReader("broken\n"), want))
+			if err == nil {
+				t.Errorf("verifyingReader (%s) reads broken content without an error", algorithm.Name)
+			}
+		}
+	})
+
+	// func replicateBlobs(from, to Repository, tags []Tag) error
+	t.Run("replicateBlobs() with the storage policy of the destination", func(t *testing.T) {
+		fileOp = &FileOp{
+			openFile: func(path string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("hello\n")), nil
+			},
+		}
+		written := make(map[string]string)
+		s3Op = &S3Op{
+			isExist: func(region string, bucket string, path string) (bool, error) {
+				return path == ".arciv/storage-policy", nil
+			},
+			loadLines: func(region string, bucket string, path string) ([]string, error) {
+				return []string{"#arciv-storage-policy", "class:STANDARD_IA smaller:1024"}, nil
+			},
+			writeBlob: func(region string, bucket string, name string, r io.Reader, storageClass string) error {
+				if _, err := ioutil.ReadAll(r); err != nil {
+					return err
+				}
+				written[name] = storageClass
+				return nil
+			},
+		}
+		hash := hashAlgorithms[0].hashBytes([]byte("hello\n"))
+		from := Repository{Name: "from", Location: RepositoryLocationFile{Path: "/from"}}
+		to := Repository{Name: "to", Location: RepositoryLocationS3{RegionName: "region", BucketName: "bucket"}}
+
+		err := replicateBlobs(from, to, []Tag{{Path: "small.txt", Hash: hash, Size: 6, UsedSize: true}})
+		if err != nil || written[".arciv/blob/"+hash.String()] != "STANDARD_IA" {
+			t.Errorf("replicateBlobs() = %v, and writes with the class %s, want STANDARD_IA", err, written[".arciv/blob/"+hash.String()])
+		}
+		// the size of a tag loaded from an old commit is unknown
+		err = replicateBlobs(from, to, []Tag{{Path: "small.txt", Hash: hash}})
+		if err != nil || written[".arciv/blob/"+hash.String()] != STORAGE_CLASS_DEFAULT {
+			t.Errorf("replicateBlobs() = %v, and writes with the class %s, want %s", err, written[".arciv/blob/"+hash.String()], STORAGE_CLASS_DEFAULT)
+		}
+	})
+}
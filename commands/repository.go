@@ -2,7 +2,9 @@ package commands
 
 import (
 	"errors"
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +20,10 @@ type RepositoryLocation interface {
 	writeLines(string, []string) error
 	loadLines(string) ([]string, error)
 	findFilePaths(string) ([]string, error)
+	isExist(string) (bool, error)
+	removeFile(string) error
+	openBlob(string) (io.ReadCloser, error)
+	writeBlob(string, io.Reader, string) error // the storage class is ignored except AWS S3
 	SendLocalBlobs([]Tag) error
 	ReceiveRemoteBlobs([]Tag) error
 }
@@ -26,15 +32,17 @@ func (repository Repository) String() string {
 	return "name:" + repository.Name + " " + repository.Location.String()
 }
 
-func (repository Repository) AddCommit(commit Commit) error {
+// AddCommit writes the commit and appends it to the timeline.
+// It returns false without an error if the commit is not written because it already exists or has the same directory structure as the latest commit.
+func (repository Repository) AddCommit(commit Commit) (bool, error) {
 	timeline, err := repository.LoadTimeline()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if isIncluded(timeline, commit.Id) {
 		message("The commit " + commit.Id + " already exists in the timeline of the repository " + repository.Name)
-		return nil
+		return false, nil
 	}
 
 	var baseCommit *Commit
@@ -42,11 +50,11 @@ func (repository Repository) AddCommit(commit Commit) error {
 		latestCommitId := timeline[len(timeline)-1]
 		if latestCommitId[9:] == commit.Hash.String() {
 			message("Committing is canceled. A commit that same directory structure already exists")
-			return nil
+			return false, nil
 		}
 		c, err := repository.LoadCommit(latestCommitId)
 		if err != nil {
-			return err
+			return false, err
 		}
 		if c.Depth < COMMIT_EXTENSION_DEPTH_MAX {
 			baseCommit = &c
@@ -54,40 +62,174 @@ func (repository Repository) AddCommit(commit Commit) error {
 	}
 	err = repository.WriteTags(commit, baseCommit)
 	if err != nil {
-		return err
+		return false, err
 	}
-	return repository.WriteTimeline(append(timeline, commit.Id))
+	err = repository.WriteTimeline(append(timeline, commit.Id))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (repository Repository) WriteTimeline(timeline []string) error {
-	return repository.Location.writeLines(".arciv/timeline", timeline)
+	err := repository.Location.writeLines(".arciv/timeline", timeline)
+	if err != nil {
+		return err
+	}
+	return repository.writeSignature(".arciv/timeline", timelineSignatureMessage(timeline))
 }
 
 func (repository Repository) LoadTimeline() ([]string, error) {
 	return repository.Location.loadLines(".arciv/timeline")
 }
 
+// LIST_FORMAT_V2 is the prefix of the first line of a tag list file which has headers (ex. '#arciv-commit-v2 atom').
+// Older versions refuse it as an unknown file type instead of reading fields and paths which they do not know.
+// A tag list file without headers is written in the legacy format, so older versions can read it.
+const LIST_FORMAT_V2 = "#arciv-commit-v2 "
+
+// listFirstLine returns the first line of a tag list file. The base is empty for an atom
+func listFirstLine(base string, versioned bool) string {
+	line := "#arciv-commit-atom"
+	if base != "" {
+		line = "#arciv-commit-extension from:" + base
+	}
+	if versioned {
+		line = LIST_FORMAT_V2 + line[len("#arciv-commit-"):]
+	}
+	return line
+}
+
+// parseListFirstLine returns the base commit id of an extension, or an empty string for an atom
+func parseListFirstLine(line string) (base string, err error) {
+	if strings.HasPrefix(line, LIST_FORMAT_V2) {
+		line = "#arciv-commit-" + line[len(LIST_FORMAT_V2):]
+	} else if strings.HasPrefix(line, "#arciv-commit-v") {
+		return "", errors.New("The tag list file is written by a newer version of arciv ('" + line + "'). Update arciv to read it")
+	}
+	if line == "#arciv-commit-atom" {
+		return "", nil
+	}
+	if strings.HasPrefix(line, "#arciv-commit-extension from:") {
+		base = line[len("#arciv-commit-extension from:"):]
+		if !isCommitId(base) {
+			return "", errors.New("The line '#arciv-commit-extension from:...' must have a commit id")
+		}
+		return base, nil
+	}
+	return "", errors.New("Unknow file type of a arciv tag list file")
+}
+
+// ListHeader is header lines "#<name>:<value>" following the first line of a tag list file written with LIST_FORMAT_V2.
+// Unknown headers are ignored.
+type ListHeader struct {
+	Fields       []string
+	Xattrs       bool   // extended attributes of tags in the file are recorded in .arciv/list/<commit-id>.xattr
+	PathEncoding string // PATH_ENCODING_PERCENT if paths are encoded. empty means raw paths
+}
+
+func (header ListHeader) Strings() (lines []string) {
+	if strings.Join(header.Fields, ",") != strings.Join(TAG_FIELDS_LEGACY, ",") {
+		lines = append(lines, "#fields:"+strings.Join(header.Fields, ","))
+	}
+	if header.Xattrs {
+		lines = append(lines, "#xattrs:true")
+	}
+	if header.PathEncoding != "" {
+		lines = append(lines, "#path-encoding:"+header.PathEncoding)
+	}
+	return lines
+}
+
+// line returns a line of a tag with fields and the path encoding of the header
+func (header ListHeader) line(tag Tag, fields []string) string {
+	if header.PathEncoding == PATH_ENCODING_PERCENT {
+		tag.Path = encodePath(tag.Path)
+	}
+	return tag.Line(fields)
+}
+
+func (header ListHeader) tag(line string, fields []string) (Tag, error) {
+	tag, err := line2Tag(line, fields)
+	if err != nil {
+		return Tag{}, err
+	}
+	switch header.PathEncoding {
+	case "":
+		return tag, nil
+	case PATH_ENCODING_PERCENT:
+		tag.Path, err = decodePath(tag.Path)
+		return tag, err
+	}
+	return Tag{}, errors.New("Unknown path encoding '" + header.PathEncoding + "'")
+}
+
+func strs2listHeader(lines []string) (header ListHeader, body []string, err error) {
+	header.Fields = TAG_FIELDS_LEGACY
+	i := 0
+	for ; i < len(lines) && strings.HasPrefix(lines[i], "#"); i++ {
+		if strings.HasPrefix(lines[i], "#fields:") {
+			header.Fields = strings.Split(lines[i][len("#fields:"):], ",")
+		}
+		if lines[i] == "#xattrs:true" {
+			header.Xattrs = true
+		}
+		if strings.HasPrefix(lines[i], "#path-encoding:") {
+			header.PathEncoding = lines[i][len("#path-encoding:"):]
+		}
+	}
+	return header, lines[i:], nil
+}
+
 func (repository Repository) WriteTags(commit Commit, base *Commit) error {
 	var lines []string
+	written := commit.Tags
 	if base == nil {
-		lines = []string{"#arciv-commit-atom"}
+		header := ListHeader{Fields: tagFields(commit.Tags), Xattrs: usedXattrs(commit.Tags), PathEncoding: pathEncodingOf(commit.Tags)}
+		lines = append([]string{listFirstLine("", len(header.Strings()) > 0)}, header.Strings()...)
 		for _, tag := range commit.Tags {
-			lines = append(lines, tag.String())
+			lines = append(lines, header.line(tag, header.Fields))
 		}
 	} else {
-		lines = []string{"#arciv-commit-extension from:" + base.Id}
 		deleted, added := diffTags(base.Tags, commit.Tags)
+		written = added
+		header := ListHeader{Fields: tagFields(added), Xattrs: usedXattrs(added), PathEncoding: pathEncodingOf(append(append([]Tag{}, deleted...), added...))}
+		lines = append([]string{listFirstLine(base.Id, len(header.Strings()) > 0)}, header.Strings()...)
+		// a deleted tag is specified by the hash and the path
 		for _, c := range deleted {
-			lines = append(lines, "- "+c.String())
+			lines = append(lines, "- "+header.line(c, TAG_FIELDS_LEGACY))
 		}
 		for _, c := range added {
-			lines = append(lines, "+ "+c.String())
+			lines = append(lines, "+ "+header.line(c, header.Fields))
 		}
 	}
 	err := repository.Location.writeLines(".arciv/list/"+commit.Id, lines)
 	if err != nil {
 		return err
 	}
+	sizes := sizeLines(commit.Id, written)
+	if sizes != nil {
+		err = repository.Location.writeLines(".arciv/list/"+commit.Id+".size", sizes)
+		if err != nil {
+			return err
+		}
+	}
+	info := commitInfoLines(commit)
+	if info != nil {
+		err = repository.Location.writeLines(".arciv/list/"+commit.Id+".info", info)
+		if err != nil {
+			return err
+		}
+	}
+	err = repository.writeSignature(".arciv/list/"+commit.Id, commitSignatureMessage(commit.Id, lines, sizes, info))
+	if err != nil {
+		return err
+	}
+	// extended attributes are verified with the commit hash
+	err = repository.writeXattrs(commit.Id, written)
+	if err != nil {
+		return err
+	}
 	lines = []string{"#arciv-timestamps of:" + commit.Id}
 	for _, tag := range commit.Tags {
 		if !tag.UsedTimestamp {
@@ -98,6 +240,62 @@ func (repository Repository) WriteTags(commit Commit, base *Commit) error {
 	return repository.Location.writeLines(".arciv/timestamps", lines)
 }
 
+// usedSizes returns true if all tags have sizes
+func usedSizes(tags []Tag) bool {
+	for _, tag := range tags {
+		if !tag.UsedSize {
+			return false
+		}
+	}
+	return len(tags) > 0
+}
+
+// sizeLines returns lines of .arciv/list/<commit-id>.size, which has sizes of tags written in .arciv/list/<commit-id> in the same order.
+// Sizes are recorded apart from the tag list file, so older versions can read the tag list file. It returns nil if any size is unknown.
+func sizeLines(commitId string, tags []Tag) []string {
+	if !usedSizes(tags) {
+		return nil
+	}
+	lines := []string{"#arciv-sizes of:" + commitId}
+	for _, tag := range tags {
+		lines = append(lines, strconv.FormatInt(tag.Size, 10))
+	}
+	return lines
+}
+
+// loadSideRecord returns lines of the file .arciv/list/<commit-id><suffix>, or nil if it does not exist
+func (repository Repository) loadSideRecord(commitId string, suffix string) ([]string, error) {
+	exist, err := repository.Location.isExist(".arciv/list/" + commitId + suffix)
+	if err != nil || !exist {
+		return nil, err
+	}
+	return repository.Location.loadLines(".arciv/list/" + commitId + suffix)
+}
+
+// loadSizes attaches sizes recorded in .arciv/list/<commit-id>.size to tags loaded from .arciv/list/<commit-id>.
+// Sizes of tags stay unknown if the commit was created by an older version.
+func (repository Repository) loadSizes(commitId string, tags []Tag) error {
+	lines, err := repository.loadSideRecord(commitId, ".size")
+	if err != nil || lines == nil {
+		return err
+	}
+	if len(lines) == 0 || lines[0] != "#arciv-sizes of:"+commitId {
+		return errors.New("The first line of .arciv/list/" + commitId + ".size must be '#arciv-sizes of:" + commitId + "'")
+	}
+	if len(lines)-1 != len(tags) {
+		return errors.New("The number of sizes in .arciv/list/" + commitId + ".size does not match the number of tags")
+	}
+	for i, line := range lines[1:] {
+		size, err := strconv.ParseInt(line, 10, 64)
+		if err != nil || size < 0 {
+			return errors.New("A size in .arciv/list/" + commitId + ".size is invalid")
+		}
+		tags[i].Size = size
+		tags[i].UsedSize = true
+	}
+	return nil
+}
+
 func (repository Repository) LoadTimestamps(commitId string) ([]Tag, error) {
 	lines, err := repository.Location.loadLines(".arciv/timestamps")
 	if err != nil {
@@ -110,23 +308,24 @@ func (repository Repository) LoadTimestamps(commitId string) ([]Tag, error) {
 	if !strings.HasPrefix(lines[0], "#arciv-timestamps of:") {
 		return []Tag{}, errors.New("The first line of .arciv/timestamps must be started with '#arciv-timestamps of:")
 	}
-	if len(lines[0]) < len("#arciv-timestamps of:")+8+1+64 {
-		return []Tag{}, errors.New("The first line of .arciv/timestamps is too short")
+	if !isCommitId(lines[0][len("#arciv-timestamps of:"):]) {
+		return []Tag{}, errors.New("The first line of .arciv/timestamps is invalid syntax")
 	}
-	if lines[0][len("#arciv-timestamps of:"):len("#arciv-timestamps of:")+8+1+64] != commitId {
+	if lines[0][len("#arciv-timestamps of:"):] != commitId {
 		// cache is old
 		return []Tag{}, nil
 	}
 	var hashAndTimestamps []Tag
 	for _, line := range lines[1:] {
-		if len(line) < 64+1+8 {
-			return []Tag{}, errors.New("a line in .arciv/timestamps is too short")
+		elements := strings.Split(line, " ")
+		if len(elements) != 2 || len(elements[1]) != 8 {
+			return []Tag{}, errors.New("a line in .arciv/timestamps is invalid syntax")
 		}
-		hash, err := hex2hash(line[:64])
+		hash, err := hex2hash(elements[0])
 		if err != nil {
 			return []Tag{}, err
 		}
-		timestamp, err := str2timestamp(line[65 : 65+8])
+		timestamp, err := str2timestamp(elements[1])
 		if err != nil {
 			return []Tag{}, err
 		}
@@ -160,35 +359,72 @@ func (repository Repository) loadTagsRecursive(commitId string, depth int) (tags
 		return []Tag{}, 0, err
 	}
 
-	// #arciv-commit-atom
-	if strings.HasPrefix(lines[0], "#arciv-commit-atom") {
-		tags, err := loadTagsFromAtom(lines[1:])
-		return tags, depth, err
+	if len(lines) == 0 {
+		return []Tag{}, 0, errors.New("The tag list file of the commit " + commitId + " is empty")
 	}
 	// backward compatible
 	if !strings.HasPrefix(lines[0], "#") {
-		tags, err := loadTagsFromAtom(lines)
+		header := ListHeader{Fields: TAG_FIELDS_LEGACY}
+		tags, err := loadTagsFromAtom(lines, header)
 		return tags, depth, err
 	}
-	// #arciv-commit-extension
-	if strings.HasPrefix(lines[0], "#arciv-commit-extension from:") {
-		if len(lines[0]) < 29+73 {
-			return []Tag{}, 0, errors.New("Length of the line '#arciv-commit-extension from:...' must be 102 or more")
+	commitIdFrom, err := parseListFirstLine(lines[0])
+	if err != nil {
+		return []Tag{}, 0, err
+	}
+	header, body, err := strs2listHeader(lines[1:])
+	if err != nil {
+		return []Tag{}, 0, err
+	}
+	// #arciv-commit-atom
+	if commitIdFrom == "" {
+		tags, err := loadTagsFromAtom(body, header)
+		if err == nil && header.Xattrs {
+			err = repository.loadXattrs(commitId, tags)
 		}
-		commitIdFrom := lines[0][len("#arciv-commit-extension from:"):]
-		tags, retDepth, err := repository.loadTagsRecursive(commitIdFrom, depth)
-		if err != nil {
-			return []Tag{}, 0, err
+		if err == nil {
+			err = repository.loadSizes(commitId, tags)
 		}
-		tags, err = loadTagsFromExtension(tags, lines[1:])
-		return tags, retDepth + 1, err
+		return tags, depth, err
 	}
-	return []Tag{}, 0, errors.New("Unknow file type of a arciv tag list file")
+	// #arciv-commit-extension
+	tags, retDepth, err = repository.loadTagsRecursive(commitIdFrom, depth)
+	if err != nil {
+		return []Tag{}, 0, err
+	}
+	tags, err = loadTagsFromExtension(tags, body, header)
+	if err != nil {
+		return []Tag{}, 0, err
+	}
+	// added tags are appended to the end
+	added := 0
+	for _, line := range body {
+		if strings.HasPrefix(line, "+") {
+			added++
+		}
+	}
+	if header.Xattrs {
+		err = repository.loadXattrs(commitId, tags[len(tags)-added:])
+	}
+	if err == nil {
+		err = repository.loadSizes(commitId, tags[len(tags)-added:])
+	}
+	return tags, retDepth + 1, err
 }
 
-func loadTagsFromAtom(body []string) (tags []Tag, err error) {
+// loadCommitInfo returns the message and CommitInfo recorded in .arciv/list/<commit-id>.info without loading tags.
+// Commits created by older versions do not have it.
+func (repository Repository) loadCommitInfo(commitId string) (string, CommitInfo, error) {
+	lines, err := repository.loadSideRecord(commitId, ".info")
+	if err != nil || lines == nil {
+		return "", CommitInfo{}, err
+	}
+	return strs2commitInfo(commitId, lines)
+}
+
+func loadTagsFromAtom(body []string, header ListHeader) (tags []Tag, err error) {
 	for _, line := range body {
-		tag, err := str2Tag(line)
+		tag, err := header.tag(line, header.Fields)
 		if err != nil {
 			return []Tag{}, err
 		}
@@ -197,13 +433,20 @@ func loadTagsFromAtom(body []string) (tags []Tag, err error) {
 	return tags, nil
 }
 
-func loadTagsFromExtension(tags []Tag, body []string) ([]Tag, error) {
+func loadTagsFromExtension(tags []Tag, body []string, header ListHeader) ([]Tag, error) {
 	for _, line := range body {
-		if len(line) <= 2+64+1 || string(line[1]) != " " {
-			return []Tag{}, errors.New("Length of lines of a commit of extension tag list must be 67 or more")
+		if len(line) <= 2 || string(line[1]) != " " {
+			return []Tag{}, errors.New("Lines of a commit of extension tag list must be '+' or '-', a space and a tag")
 		}
 
-		tag, err := str2Tag(line[2:])
+		// a deleted tag is specified by the hash and the path
+		var tag Tag
+		var err error
+		if string(line[0]) == "-" {
+			tag, err = header.tag(line[2:], TAG_FIELDS_LEGACY)
+		} else {
+			tag, err = header.tag(line[2:], header.Fields)
+		}
 		if err != nil {
 			return []Tag{}, err
 		}
@@ -248,7 +491,11 @@ func (repository Repository) LoadCommitFromAlias(alias string) (Commit, error) {
 	if err != nil {
 		return Commit{}, err
 	}
-	commitId, err := findCommitId(alias, timeline)
+	labels, err := repository.revisionLabels(alias)
+	if err != nil {
+		return Commit{}, err
+	}
+	commitId, err := findRevision(alias, timeline, labels)
 	if err != nil {
 		return Commit{}, err
 	}
@@ -256,25 +503,22 @@ func (repository Repository) LoadCommitFromAlias(alias string) (Commit, error) {
 }
 
 func (repository Repository) LoadCommit(commitId string) (Commit, error) {
-	if len(commitId) != 73 {
-		return Commit{}, errors.New("Length of a commit id must be 73.")
-	}
-	tags, depth, err := repository.LoadTags(commitId)
+	timestamp, hash, err := parseCommitId(commitId)
 	if err != nil {
 		return Commit{}, err
 	}
-	sort.Slice(tags, func(i, j int) bool {
-		return compareTag(tags[i], tags[j]) < 0
-	})
-	timestamp, err := str2timestamp(commitId[:8])
+	tags, depth, err := repository.LoadTags(commitId)
 	if err != nil {
 		return Commit{}, err
 	}
-	hash, err := hex2hash(commitId[9:])
+	message, info, err := repository.loadCommitInfo(commitId)
 	if err != nil {
 		return Commit{}, err
 	}
-	return Commit{Id: commitId, Timestamp: timestamp, Hash: hash, Tags: tags, Depth: depth}, nil
+	sort.Slice(tags, func(i, j int) bool {
+		return compareTag(tags[i], tags[j]) < 0
+	})
+	return Commit{Id: commitId, Timestamp: timestamp, Hash: hash, Tags: tags, Depth: depth, Message: message, Info: info}, nil
 }
 
 func (repository Repository) FetchBlobHashes() (blobs []string, err error) {
@@ -282,12 +526,13 @@ func (repository Repository) FetchBlobHashes() (blobs []string, err error) {
 	if err != nil {
 		return []string{}, err
 	}
-	for i, filename := range filenames {
-		if len(filename) != 64 {
-			filenames = append(filenames[:i], filenames[i+1:]...)
+	for _, filename := range filenames {
+		// exclude temporary files (ex. *.download)
+		if _, err := hex2hash(filename); err == nil {
+			blobs = append(blobs, filename)
 		}
 	}
-	return filenames, nil
+	return blobs, nil
 }
 
 // send from repository's root directory
@@ -295,12 +540,12 @@ func (repository Repository) SendLocalBlobs(tags []Tag) (err error) {
 	return repository.Location.SendLocalBlobs(tags)
 }
 
-func (r Repository) ReceiveRemoteBlobsRequest(tags []Tag, validDays int32) (blobsRequested []string, err error) {
+func (r Repository) ReceiveRemoteBlobsRequest(tags []Tag, validDays int32, tier string) (blobsRequested []string, err error) {
 	repositoryLocationS3, ok := r.Location.(RepositoryLocationS3)
 	if !ok {
 		return []string{}, errors.New("Repository.ReceiveRemoteBlobsRequest() is not succeeded with repository s3")
 	}
-	return repositoryLocationS3.ReceiveRemoteBlobsRequest(tags, validDays)
+	return repositoryLocationS3.ReceiveRemoteBlobsRequest(tags, validDays, tier)
 }
 
 // receive to .arciv/blob
@@ -331,6 +576,46 @@ func findCommitId(alias string, commitIds []string) (foundCId string, err error)
 	return foundCId, nil
 }
 
+// findCommitRange returns commit ids of the timeline in the range "<from>..<to>" including both ends.
+// An omitted end means the first or the latest commit, and a single revision means the commit.
+func findCommitRange(commitRange string, timeline []string, labels []Label) ([]string, error) {
+	from, to := commitRange, commitRange
+	if idx := strings.Index(commitRange, ".."); idx != -1 {
+		from, to = commitRange[:idx], commitRange[idx+2:]
+	}
+	if len(timeline) == 0 {
+		return []string{}, errors.New("Commit does not exists")
+	}
+	fromIndex, toIndex := 0, len(timeline)-1
+	if from != "" {
+		id, err := findRevision(from, timeline, labels)
+		if err != nil {
+			return []string{}, err
+		}
+		fromIndex = indexOf(timeline, id)
+	}
+	if to != "" {
+		id, err := findRevision(to, timeline, labels)
+		if err != nil {
+			return []string{}, err
+		}
+		toIndex = indexOf(timeline, id)
+	}
+	if fromIndex > toIndex {
+		return []string{}, errors.New("The commit '" + from + "' is newer than the commit '" + to + "'")
+	}
+	return timeline[fromIndex : toIndex+1], nil
+}
+
+func indexOf(strs []string, s string) int {
+	for i, str := range strs {
+		if str == s {
+			return i
+		}
+	}
+	return -1
+}
+
 func findRestoreRequestId(alias string, ids []string) (foundRId string, err error) {
 	for _, id := range ids {
 		if !strings.HasPrefix(id, alias) {
@@ -369,5 +654,9 @@ func (r Repository) WriteRestoreRequest(restoreRequestId string, restoreRequest
 }
 
 func SelfRepo() Repository {
-	return Repository{Name: "self", Location: RepositoryLocationFile{Path: fileOp.rootDir()}}
+	dir, err := arcivDirSetting()
+	if err != nil {
+		Exit(err, 1)
+	}
+	return Repository{Name: "self", Location: RepositoryLocationFile{Path: fileOp.rootDir(), ArcivDir: dir}}
 }
@@ -1,29 +1,59 @@
 package commands
 
+import (
+	"io"
+	"strings"
+)
+
 type RepositoryLocationFile struct {
-	Path string
+	Path     string
+	ArcivDir string // the metadata directory if it is not '<Path>/.arciv' (--arciv-dir). only for the self repository
 }
 
 func (r RepositoryLocationFile) String() string {
 	return "type:file path:" + r.Path
 }
 
+// path returns the path of relativePath in the repository. A path in .arciv is in ArcivDir if it is set.
+func (repositoryLocationFile RepositoryLocationFile) path(relativePath string) string {
+	if repositoryLocationFile.ArcivDir != "" && (relativePath == ".arciv" || strings.HasPrefix(relativePath, ".arciv/")) {
+		return repositoryLocationFile.ArcivDir + relativePath[len(".arciv"):]
+	}
+	return repositoryLocationFile.Path + "/" + relativePath
+}
+
 func (repositoryLocationFile RepositoryLocationFile) writeLines(relativePath string, lines []string) error {
-	return fileOp.writeLines(repositoryLocationFile.Path+"/"+relativePath, lines)
+	return fileOp.writeLines(repositoryLocationFile.path(relativePath), lines)
 }
 
 func (repositoryLocationFile RepositoryLocationFile) loadLines(relativePath string) (lines []string, err error) {
-	return fileOp.loadLines(repositoryLocationFile.Path + "/" + relativePath)
+	return fileOp.loadLines(repositoryLocationFile.path(relativePath))
 }
 
 func (repositoryLocationFile RepositoryLocationFile) findFilePaths(root string) (relativePaths []string, err error) {
-	return fileOp.findFilePaths(repositoryLocationFile.Path + "/" + root)
+	return fileOp.findFilePaths(repositoryLocationFile.path(root))
+}
+
+func (repositoryLocationFile RepositoryLocationFile) isExist(relativePath string) (bool, error) {
+	return fileOp.isExist(repositoryLocationFile.path(relativePath))
+}
+
+func (repositoryLocationFile RepositoryLocationFile) removeFile(relativePath string) error {
+	return fileOp.removeFile(repositoryLocationFile.path(relativePath))
+}
+
+func (repositoryLocationFile RepositoryLocationFile) openBlob(blob string) (io.ReadCloser, error) {
+	return fileOp.openFile(repositoryLocationFile.path(".arciv/blob/" + blob))
+}
+
+func (repositoryLocationFile RepositoryLocationFile) writeBlob(blob string, r io.Reader, storageClass string) error {
+	return fileOp.writeFile(repositoryLocationFile.path(".arciv/blob/"+blob), r)
 }
 
 func (repositoryLocationFile RepositoryLocationFile) SendLocalBlobs(tags []Tag) (err error) {
 	for _, tag := range tags {
-		from := fileOp.rootDir() + "/" + tag.Path
-		to := repositoryLocationFile.Path + "/.arciv/blob/" + tag.Hash.String()
+		from := fileOp.rootDir() + "/" + tag.localPath()
+		to := repositoryLocationFile.path(".arciv/blob/" + tag.Hash.String())
 		err = fileOp.copyFile(from, to)
 		if err != nil {
 			return err
@@ -35,8 +65,8 @@ func (repositoryLocationFile RepositoryLocationFile) SendLocalBlobs(tags []Tag)
 
 func (repositoryLocationFile RepositoryLocationFile) ReceiveRemoteBlobs(tags []Tag) (err error) {
 	for _, tag := range tags {
-		from := repositoryLocationFile.Path + "/.arciv/blob/" + tag.Hash.String()
-		to := fileOp.rootDir() + "/.arciv/blob/" + tag.Hash.String()
+		from := repositoryLocationFile.path(".arciv/blob/" + tag.Hash.String())
+		to := arcivDir() + "/blob/" + tag.Hash.String()
 		err = fileOp.copyFile(from, to)
 		if err != nil {
 			return err
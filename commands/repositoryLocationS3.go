@@ -2,7 +2,9 @@ package commands
 
 import (
 	"errors"
+	"io"
 	"strings"
+	"time"
 )
 
 type RepositoryLocationS3 struct {
@@ -26,17 +28,41 @@ func (r RepositoryLocationS3) findFilePaths(root string) (relativePaths []string
 	return s3Op.findFilePaths(r.RegionName, r.BucketName, root)
 }
 
+func (r RepositoryLocationS3) isExist(relativePath string) (bool, error) {
+	return s3Op.isExist(r.RegionName, r.BucketName, relativePath)
+}
+
+func (r RepositoryLocationS3) removeFile(relativePath string) error {
+	return s3Op.removeFile(r.RegionName, r.BucketName, relativePath)
+}
+
+// SendLocalBlobs uploads blobs with storage classes chosen by the storage policy of the repository
 func (r RepositoryLocationS3) SendLocalBlobs(tags []Tag) (err error) {
+	rules, err := loadStoragePolicy(r)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
 	var fromPaths []string
 	var blobNames []string
+	var storageClasses []string
 	for _, tag := range tags {
-		fromPaths = append(fromPaths, fileOp.rootDir()+"/"+tag.Path)
+		fromPaths = append(fromPaths, fileOp.rootDir()+"/"+tag.localPath())
 		blobNames = append(blobNames, ".arciv/blob/"+tag.Hash.String())
+		storageClasses = append(storageClasses, storageClassOf(rules, tag, now))
 	}
-	return s3Op.sendBlobs(r.RegionName, r.BucketName, fromPaths, blobNames)
+	return s3Op.sendBlobs(r.RegionName, r.BucketName, fromPaths, blobNames, storageClasses)
+}
+
+func (r RepositoryLocationS3) openBlob(blob string) (io.ReadCloser, error) {
+	return s3Op.openBlob(r.RegionName, r.BucketName, ".arciv/blob/"+blob)
+}
+
+func (r RepositoryLocationS3) writeBlob(blob string, reader io.Reader, storageClass string) error {
+	return s3Op.writeBlob(r.RegionName, r.BucketName, ".arciv/blob/"+blob, reader, storageClass)
 }
 
-func (r RepositoryLocationS3) ReceiveRemoteBlobsRequest(tags []Tag, validDays int32) (blobsRequested []string, err error) {
+func (r RepositoryLocationS3) ReceiveRemoteBlobsRequest(tags []Tag, validDays int32, tier string) (blobsRequested []string, err error) {
 	var keys []string
 	for _, tag := range tags {
 		key := ".arciv/blob/" + tag.Hash.String()
@@ -44,7 +70,7 @@ func (r RepositoryLocationS3) ReceiveRemoteBlobsRequest(tags []Tag, validDays in
 			keys = append(keys, key)
 		}
 	}
-	keysRequested, err := s3Op.receiveBlobsRequest(r.RegionName, r.BucketName, keys, validDays)
+	keysRequested, err := s3Op.receiveBlobsRequest(r.RegionName, r.BucketName, keys, validDays, tier)
 	// Error check is not needed here! Even if error occures, len(keysRequested) may not zero.
 	for _, key := range keysRequested {
 		if !strings.HasPrefix(key, ".arciv/blob/") {
@@ -58,7 +84,7 @@ func (r RepositoryLocationS3) ReceiveRemoteBlobsRequest(tags []Tag, validDays in
 func (r RepositoryLocationS3) ReceiveRemoteBlobs(tags []Tag) (err error) {
 	var toPaths []string
 	var keys []string
-	base := fileOp.rootDir() + "/.arciv/blob/"
+	base := arcivDir() + "/blob/"
 	for _, tag := range tags {
 		blob := tag.Hash.String()
 		toPaths = append(toPaths, base+blob)
@@ -1,7 +1,11 @@
 package commands
 
 import (
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestRepository(t *testing.T) {
@@ -15,6 +19,26 @@ func TestRepository(t *testing.T) {
 		}
 	})
 
+	// func (repositoryLocationFile RepositoryLocationFile) path(relativePath string) string
+	t.Run("RepositoryLocationFile.path()", func(t *testing.T) {
+		location := RepositoryLocationFile{Path: "root", ArcivDir: "/meta/arciv"}
+		cases := map[string]string{
+			".arciv":             "/meta/arciv",
+			".arciv/timeline":    "/meta/arciv/timeline",
+			".arciv/blob/0000":   "/meta/arciv/blob/0000",
+			".arcivignore":       "root/.arcivignore",
+			"photos/.arciv/blob": "root/photos/.arciv/blob",
+		}
+		for relativePath, want := range cases {
+			if got := location.path(relativePath); got != want {
+				t.Errorf("RepositoryLocationFile.path(%s) = %s, want %s", relativePath, got, want)
+			}
+		}
+		if got := (RepositoryLocationFile{Path: "root"}).path(".arciv/timeline"); got != "root/.arciv/timeline" {
+			t.Errorf("RepositoryLocationFile.path() = %s, want root/.arciv/timeline", got)
+		}
+	})
+
 	// func (repository Repository) WriteTimeline(timeline []string) error
 	// use fileOp.writeLines()
 	t.Run("Repository.WriteTimeline()", func(t *testing.T) {
@@ -29,6 +53,9 @@ func TestRepository(t *testing.T) {
 				}
 				return nil
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
 
 		err := repo.WriteTimeline([]string{
@@ -120,6 +147,9 @@ func TestRepository(t *testing.T) {
 				}
 				return nil
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
 		commit := Commit{
 			Id: "aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
@@ -147,6 +177,9 @@ func TestRepository(t *testing.T) {
 				}
 				return nil
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
 		base := Commit{
 			Id: "bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
@@ -162,6 +195,261 @@ func TestRepository(t *testing.T) {
 		}
 	})
 
+	// func (repository Repository) WriteTags(commit Commit, base *Commit) error
+	// func (repository Repository) LoadTags(commitId string) (tags []Tag, depth int, err error)
+	// sizes of tags are written in .arciv/list/<commit-id>.size, and lines of the tag list file are readable by str2Tag()
+	t.Run("Repository.WriteTags() and Repository.LoadTags() with sizes", func(t *testing.T) {
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+		}
+		base := Commit{
+			Id: "bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			Tags: []Tag{
+				Tag{Path: "0000/0000", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000")},
+				Tag{Path: "2222/2222", Hash: hashing("2222222222222222222222222222222222222222222222222222222222222222")},
+			},
+		}
+		commit := Commit{
+			Id: "aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			Tags: []Tag{
+				Tag{Path: "0000/0000", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"), Size: 0, UsedSize: true},
+				Tag{Path: "1111/1111", Hash: hashing("1111111111111111111111111111111111111111111111111111111111111111"), Size: 4096, UsedSize: true},
+			},
+		}
+		err := repo.WriteTags(base, nil)
+		if err != nil {
+			t.Errorf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+		err = repo.WriteTags(commit, &base)
+		if err != nil {
+			t.Errorf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+		lines := files["root/.arciv/list/"+commit.Id]
+		if len(lines) != 3 ||
+			lines[0] != "#arciv-commit-extension from:bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb" ||
+			lines[1] != "- 2222222222222222222222222222222222222222222222222222222222222222 2222/2222" ||
+			lines[2] != "+ 1111111111111111111111111111111111111111111111111111111111111111 1111/1111" {
+			t.Errorf("Repository.WriteTags() writes lines %s", lines)
+		}
+		for _, line := range lines[1:] {
+			if tag, err := str2Tag(line[2:]); err != nil || strings.Contains(tag.Path, " ") {
+				t.Errorf("str2Tag(%s) = (%s, %v)", line[2:], tag, err)
+			}
+		}
+		sizes := files["root/.arciv/list/"+commit.Id+".size"]
+		if len(sizes) != 2 || sizes[0] != "#arciv-sizes of:"+commit.Id || sizes[1] != "4096" {
+			t.Errorf("Repository.WriteTags() writes sizes %s", sizes)
+		}
+		if _, ok := files["root/.arciv/list/"+base.Id+".size"]; ok {
+			t.Errorf("Repository.WriteTags() writes sizes of the commit without sizes")
+		}
+
+		tags, _, err := repo.LoadTags(commit.Id)
+		if err != nil {
+			t.Errorf("Repository.LoadTags() return error \"%s\", want nil", err)
+		}
+		if len(tags) != 2 || tags[0].UsedSize || !tags[1].UsedSize || tags[1].Size != 4096 {
+			t.Errorf("Repository.LoadTags() = %+v", tags)
+		}
+
+		files["root/.arciv/list/"+commit.Id+".size"] = []string{"#arciv-sizes of:" + commit.Id, "4096", "1"}
+		_, _, err = repo.LoadTags(commit.Id)
+		if err == nil {
+			t.Errorf("Repository.LoadTags() return nil with sizes more than tags, want an error")
+		}
+	})
+
+	// func (repository Repository) WriteTags(commit Commit, base *Commit) error
+	// func (repository Repository) LoadTags(commitId string) (tags []Tag, depth int, err error)
+	// paths which need encoding are written and loaded again
+	t.Run("Repository.WriteTags() and Repository.LoadTags() with unusual paths and a message", func(t *testing.T) {
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+		}
+		paths := []string{
+			"new\nline",
+			"carriage\rreturn",
+			"tab\tseparated",
+			"100%/%41",
+			" leading and trailing spaces ",
+			"invalid/\xff\xfe.txt",
+			strings.Repeat("long/", 1000),
+			"日本語.txt",
+		}
+		var tags []Tag
+		for i, path := range paths {
+			tags = append(tags, Tag{Path: path, Hash: hashing(strings.Repeat(strconv.Itoa(i), 64)), Xattrs: []Xattr{{Name: "user.index", Value: []byte{byte(i)}}}, UsedXattrs: true})
+		}
+		commit := Commit{Id: "aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Tags: tags, Message: "photos\n100% done"}
+		err := repo.WriteTags(commit, nil)
+		if err != nil {
+			t.Errorf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+		lines := files["root/.arciv/list/"+commit.Id]
+		// older versions refuse the first line as an unknown file type
+		if len(lines) != len(paths)+3 || lines[0] != "#arciv-commit-v2 atom" || lines[2] != "#path-encoding:percent" {
+			t.Errorf("Repository.WriteTags() writes lines %q", lines)
+		}
+		info := files["root/.arciv/list/"+commit.Id+".info"]
+		if len(info) != 2 || info[1] != "message:photos%0A100%25 done" {
+			t.Errorf("Repository.WriteTags() writes the commit info %q", info)
+		}
+		for _, line := range lines {
+			if strings.ContainsAny(line, "\n\r") || !utf8.ValidString(line) {
+				t.Errorf("Repository.WriteTags() writes a line %q", line)
+			}
+		}
+		loaded, err := repo.LoadCommit(commit.Id)
+		if err != nil {
+			t.Errorf("Repository.LoadCommit() return error \"%s\", want nil", err)
+		}
+		if loaded.Message != commit.Message {
+			t.Errorf("Repository.LoadCommit() return the message %q, want %q", loaded.Message, commit.Message)
+		}
+		got, _, err := repo.LoadTags(commit.Id)
+		if err != nil {
+			t.Errorf("Repository.LoadTags() return error \"%s\", want nil", err)
+		}
+		if len(got) != len(paths) {
+			t.Fatalf("Repository.LoadTags() = %s", got)
+		}
+		for i, path := range paths {
+			if got[i].Path != path || got[i].Hash.String() != tags[i].Hash.String() {
+				t.Errorf("Repository.LoadTags() return a path %q, want %q", got[i].Path, path)
+			}
+			if len(got[i].Xattrs) != 1 || got[i].Xattrs[0].Value[0] != byte(i) {
+				t.Errorf("Repository.LoadTags() return xattrs %v of %q", got[i].Xattrs, path)
+			}
+		}
+
+		// the header is not written if no path needs encoding
+		commit = Commit{Id: "bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", Tags: []Tag{tags[7]}}
+		commit.Tags[0].Xattrs, commit.Tags[0].UsedXattrs = nil, false
+		err = repo.WriteTags(commit, nil)
+		if err != nil {
+			t.Errorf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+		lines = files["root/.arciv/list/"+commit.Id]
+		if len(lines) != 2 || lines[1] != "7777777777777777777777777777777777777777777777777777777777777777 日本語.txt" {
+			t.Errorf("Repository.WriteTags() writes lines %q", lines)
+		}
+	})
+
+	// func listFirstLine(base string, versioned bool) string
+	// func parseListFirstLine(line string) (base string, err error)
+	t.Run("listFirstLine() and parseListFirstLine()", func(t *testing.T) {
+		base := "bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		cases := []struct {
+			base      string
+			versioned bool
+			want      string
+		}{
+			{"", false, "#arciv-commit-atom"},
+			{base, false, "#arciv-commit-extension from:" + base},
+			{"", true, "#arciv-commit-v2 atom"},
+			{base, true, "#arciv-commit-v2 extension from:" + base},
+		}
+		for _, c := range cases {
+			line := listFirstLine(c.base, c.versioned)
+			if line != c.want {
+				t.Errorf("listFirstLine(%q, %v) = %q, want %q", c.base, c.versioned, line, c.want)
+			}
+			// older versions read only the legacy first lines
+			if c.versioned && (strings.HasPrefix(line, "#arciv-commit-atom") || strings.HasPrefix(line, "#arciv-commit-extension from:")) {
+				t.Errorf("listFirstLine(%q, true) = %q is read by older versions", c.base, line)
+			}
+			got, err := parseListFirstLine(line)
+			if err != nil || got != c.base {
+				t.Errorf("parseListFirstLine(%q) = (%q, %v), want %q", line, got, err, c.base)
+			}
+		}
+		for _, line := range []string{"#arciv-commit-v3 atom", "#arciv-commit-extension from:bbbb", "#unknown"} {
+			if _, err := parseListFirstLine(line); err == nil {
+				t.Errorf("parseListFirstLine(%q) return nil, want an error", line)
+			}
+		}
+	})
+
+	// func (repository Repository) loadCommitInfo(commitId string) (string, CommitInfo, error)
+	// CommitInfo is written in .arciv/list/<commit-id>.info and loaded without tags. The tag list file stays readable by older versions
+	t.Run("Repository.WriteTags() and Repository.loadCommitInfo() with CommitInfo", func(t *testing.T) {
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+		}
+		info := CommitInfo{Host: "my host", User: "user", Version: "1.0.0", Parents: []string{"aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "cccccccc-cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"}, Files: 1, Bytes: 12345, UsedFiles: true, UsedBytes: true}
+		commit := Commit{Id: "bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", Tags: []Tag{{Path: "path", Hash: hashing(strings.Repeat("0", 64))}}, Info: info}
+		err := repo.WriteTags(commit, nil)
+		if err != nil {
+			t.Errorf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+		lines := files["root/.arciv/list/"+commit.Id]
+		if len(lines) != 2 || lines[0] != "#arciv-commit-atom" || lines[1] != "0000000000000000000000000000000000000000000000000000000000000000 path" {
+			t.Errorf("Repository.WriteTags() writes lines %q", lines)
+		}
+		msg, got, err := repo.loadCommitInfo(commit.Id)
+		if err != nil {
+			t.Errorf("Repository.loadCommitInfo() return error \"%s\", want nil", err)
+		}
+		if msg != "" || !reflect.DeepEqual(got, info) {
+			t.Errorf("Repository.loadCommitInfo() return (%q, %+v), want the info %+v", msg, got, info)
+		}
+		loaded, err := repo.LoadCommit(commit.Id)
+		if err != nil {
+			t.Errorf("Repository.LoadCommit() return error \"%s\", want nil", err)
+		}
+		if !reflect.DeepEqual(loaded.Info, info) || len(loaded.Tags) != 1 {
+			t.Errorf("Repository.LoadCommit() return the info %+v and tags %s", loaded.Info, loaded.Tags)
+		}
+
+		// an unknown header or line written by a newer version is ignored
+		files["root/.arciv/list/"+commit.Id] = []string{"#arciv-commit-v2 atom", "#unknown:value", "0000000000000000000000000000000000000000000000000000000000000000 path"}
+		files["root/.arciv/list/"+commit.Id+".info"] = append(files["root/.arciv/list/"+commit.Id+".info"], "unknown:value")
+		loaded, err = repo.LoadCommit(commit.Id)
+		if err != nil || len(loaded.Tags) != 1 || !reflect.DeepEqual(loaded.Info, info) {
+			t.Errorf("Repository.LoadCommit() return %+v, %v", loaded, err)
+		}
+
+		// a commit created by an older version does not have the commit info
+		delete(files, "root/.arciv/list/"+commit.Id+".info")
+		loaded, err = repo.LoadCommit(commit.Id)
+		if err != nil || len(loaded.Tags) != 1 || !reflect.DeepEqual(loaded.Info, CommitInfo{}) {
+			t.Errorf("Repository.LoadCommit() return %+v, %v", loaded, err)
+		}
+	})
+
 	fileOp = &FileOp{
 		loadLines: func(path string) ([]string, error) {
 			if path == "root/.arciv/timestamps" {
@@ -201,15 +489,30 @@ func TestRepository(t *testing.T) {
 				}, nil
 			} else if path == "root/.arciv/list/eeeeeeee-eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee" {
 				return []string{
-					"#arciv-commit-extension from:dddddddd-dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd",
+					"#arciv-commit-v2 extension from:dddddddd-dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd",
+					"#xattrs:true",
 					"- ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff ffff/ffff",
 					"+ 3333333333333333333333333333333333333333333333333333333333333333 3333/3333",
 					"+ 4444444444444444444444444444444444444444444444444444444444444444 4444/4444",
 				}, nil
+			} else if path == "root/.arciv/list/eeeeeeee-eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee.size" {
+				return []string{
+					"#arciv-sizes of:eeeeeeee-eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee",
+					"3",
+					"4",
+				}, nil
+			} else if path == "root/.arciv/list/eeeeeeee-eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee.xattr" {
+				return []string{
+					"#arciv-xattrs of:eeeeeeee-eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee",
+					"user.xdg.tags 7265642c626c7565 3333/3333",
+				}, nil
 			} else {
 				panic("fileOp.loadLines is called with unknown path: " + path)
 			}
 		},
+		isExist: func(path string) (bool, error) {
+			return path == "root/.arciv/list/eeeeeeee-eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee.size", nil
+		},
 	}
 	// func (repository Repository) LoadTags(commitId string) (tags []Tag, err error)
 	// func (repository Repository) LoadTagsFromExtension(baseCommitId string, body []string) ([]Tag, error)
@@ -232,6 +535,9 @@ func TestRepository(t *testing.T) {
 		if gotDepth != 2 {
 			t.Errorf("Repository.LoadTags() return depth: %d, want 2", gotDepth)
 		}
+		if len(got) == 7 && (got[5].Size != 3 || !got[5].UsedSize || got[0].UsedSize) {
+			t.Errorf("Repository.LoadTags() return sizes %d (%t), %t", got[5].Size, got[5].UsedSize, got[0].UsedSize)
+		}
 	})
 
 	// func (repository Repository) LoadCommit(commitId string) (Commit, error)
@@ -255,6 +561,9 @@ func TestRepository(t *testing.T) {
 			got.Tags[6].Hash.String() != "6666666666666666666666666666666666666666666666666666666666666666" || got.Tags[6].Timestamp != 0x66666666 || got.Tags[6].Path != "6666/6666" {
 			t.Errorf("Repository.LoadCommit() return %s", got.Id)
 		}
+		if len(got.Tags) == 7 && (!got.Tags[3].UsedXattrs || len(got.Tags[3].Xattrs) != 1 || got.Tags[3].Xattrs[0].Name != "user.xdg.tags" || string(got.Tags[3].Xattrs[0].Value) != "red,blue" || got.Tags[0].UsedXattrs) {
+			t.Errorf("Repository.LoadCommit() return xattrs %v", got.Tags[3].Xattrs)
+		}
 	})
 
 	// func (repository Repository) LoadCommitFromAlias(alias string) (Commit, error)
@@ -308,6 +617,9 @@ func TestRepository(t *testing.T) {
 					panic("")
 				}
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
 		got, err := repo.LoadLatestCommit()
 		if err != nil {
@@ -450,9 +762,40 @@ func TestRepository(t *testing.T) {
 		}
 	})
 
+	// func findCommitRange(commitRange string, timeline []string, labels []Label) ([]string, error)
+	t.Run("findCommitRange()", func(t *testing.T) {
+		timeline := []string{
+			"00000000-0000000000000000000000000000000000000000000000000000000000000000",
+			"11111111-1111111111111111111111111111111111111111111111111111111111111111",
+			"22222222-2222222222222222222222222222222222222222222222222222222222222222",
+			"33333333-3333333333333333333333333333333333333333333333333333333333333333",
+		}
+		labels := []Label{{Name: "before-reorg", CommitId: timeline[1]}}
+		cases := map[string][]string{
+			"11..22":           timeline[1:3],
+			"before-reorg..22": timeline[1:3],
+			"..before-reorg":   timeline[:2],
+			"11..":             timeline[1:],
+			"..11":             timeline[:2],
+			"..":               timeline,
+			"22":               timeline[2:3],
+		}
+		for commitRange, want := range cases {
+			got, err := findCommitRange(commitRange, timeline, labels)
+			if err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("findCommitRange(%s) = (%s, %v), want %s", commitRange, got, err, want)
+			}
+		}
+		for _, commitRange := range []string{"22..11", "44..", "..44", "after-reorg.."} {
+			if _, err := findCommitRange(commitRange, timeline, labels); err == nil {
+				t.Errorf("findCommitRange(%s) return nil, want an error", commitRange)
+			}
+		}
+	})
+
 	// func SelfRepo() Repository
 
-	// func (repository Repository) AddCommit(commit Commit) error
+	// func (repository Repository) AddCommit(commit Commit) (bool, error)
 	//   use Repository.LoadTimeline(), Repository.LoadCommit(), Repository.WriteTags(), Repository.WriteTimeline()
 	//   use fileOp.loadLines(), fileOp.writeLines()
 	// append commit
@@ -499,16 +842,19 @@ func TestRepository(t *testing.T) {
 					panic("")
 				}
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
-		err := repo.AddCommit(Commit{
+		written, err := repo.AddCommit(Commit{
 			Id: "22222222-2222222222222222222222222222222222222222222222222222222222222222",
 			Tags: []Tag{
 				Tag{Path: "aaaa/aaaa", Hash: hashing("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"), Timestamp: 0xaaaaaaaa},
 				Tag{Path: "bbbb/bbbb", Hash: hashing("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"), Timestamp: 0xbbbbbbbb},
 			},
 		})
-		if err != nil {
-			t.Errorf("Repository.AddCommit() return an error %s, want nil", err)
+		if err != nil || !written {
+			t.Errorf("Repository.AddCommit() return (%t, %v), want (true, nil)", written, err)
 		}
 		// initial commit
 		fileOp = &FileOp{
@@ -541,15 +887,36 @@ func TestRepository(t *testing.T) {
 					panic("")
 				}
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
-		err = repo.AddCommit(Commit{
+		written, err = repo.AddCommit(Commit{
 			Id: "00000000-0000000000000000000000000000000000000000000000000000000000000000",
 			Tags: []Tag{
 				Tag{Path: "aaaa/aaaa", Hash: hashing("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"), Timestamp: 0xaaaaaaaa},
 			},
 		})
-		if err != nil {
-			t.Errorf("Repository.AddCommit() return an error %s, want nil", err)
+		if err != nil || !written {
+			t.Errorf("Repository.AddCommit() return (%t, %v), want (true, nil)", written, err)
+		}
+
+		// the same directory structure as the latest commit is not written
+		fileOp = &FileOp{
+			loadLines: func(path string) ([]string, error) {
+				return []string{"00000000-0000000000000000000000000000000000000000000000000000000000000000"}, nil
+			},
+			writeLines: func(path string, lines []string) error {
+				t.Errorf("fileOp.writeLines is called with the path %s, want no call", path)
+				return nil
+			},
+		}
+		written, err = repo.AddCommit(Commit{
+			Id:   "11111111-0000000000000000000000000000000000000000000000000000000000000000",
+			Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"),
+		})
+		if err != nil || written {
+			t.Errorf("Repository.AddCommit() return (%t, %v), want (false, nil)", written, err)
 		}
 
 		// commit with depth >= COMMIT_EXTENSION_DEPTH_MAX
@@ -666,8 +1033,11 @@ func TestRepository(t *testing.T) {
 					panic("")
 				}
 			},
+			isExist: func(path string) (bool, error) {
+				return false, nil
+			},
 		}
-		err = repo.AddCommit(Commit{
+		written, err = repo.AddCommit(Commit{
 			Id: "aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
 			Tags: []Tag{
 				Tag{Path: "0000/0000", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"), Timestamp: 0x00000000},
@@ -683,12 +1053,12 @@ func TestRepository(t *testing.T) {
 				Tag{Path: "aaaa/aaaa", Hash: hashing("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"), Timestamp: 0xaaaaaaaa},
 			},
 		})
-		if err != nil {
-			t.Errorf("Repository.AddCommit() return an error %s, want nil", err)
+		if err != nil || !written {
+			t.Errorf("Repository.AddCommit() return (%t, %v), want (true, nil)", written, err)
 		}
 	})
 	// FIXME: Please write tests
-	// func (r Repository) ReceiveRemoteBlobsRequest(tags []Tag, validDays int32) (blobsRequested []string, err error)
+	// func (r Repository) ReceiveRemoteBlobsRequest(tags []Tag, validDays int32, tier string) (blobsRequested []string, err error)
 	// func findRestoreRequestId(alias string, ids []string) (foundRId string, err error)
 	// func (r Repository) LoadRestoreRequest(restoreRequestAlias string) (restoreRequestId string, restoreRequest RestoreRequest, err error)
 	// func (r Repository) WriteRestoreRequest(restoreRequestId string, restoreRequest RestoreRequest) error
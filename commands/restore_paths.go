@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"strings"
+)
+
+// cleanRestorePath removes "./" at the head and "/" at the tail of a pattern
+func cleanRestorePath(pattern string) string {
+	pattern = strings.TrimPrefix(pattern, "./")
+	return strings.TrimRight(pattern, "/")
+}
+
+// matchRestorePaths returns true if the path matches one of glob patterns.
+// "**" matches zero or more directories, and a pattern of a directory matches files in it.
+func matchRestorePaths(patterns []string, path string) bool {
+	segments := strings.Split(path, "/")
+	for _, pattern := range patterns {
+		patternSegments := strings.Split(cleanRestorePath(pattern), "/")
+		if matchSegments(patternSegments, segments) || matchSegments(append(patternSegments, "**"), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectRestoreTags returns tags whose paths match patterns. All tags are returned if patterns is empty.
+func selectRestoreTags(tags []Tag, patterns []string) (selected []Tag) {
+	if len(patterns) == 0 {
+		return tags
+	}
+	for _, tag := range tags {
+		if matchRestorePaths(patterns, tag.Path) {
+			selected = append(selected, tag)
+		}
+	}
+	return selected
+}
+
+// detachHardlinks returns tags in which hardlinks to a file out of the tags are restored without the file.
+// The first hardlink of the group becomes a file, and the others are linked to it,
+// because a partial restore must not replace the file out of the patterns.
+func detachHardlinks(tags []Tag) []Tag {
+	paths := make(map[string]struct{})
+	for _, tag := range tags {
+		paths[tag.Path] = struct{}{}
+	}
+	firstOf := make(map[string]string)
+	detached := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag.entryType() != TAG_TYPE_HARDLINK {
+			detached = append(detached, tag)
+			continue
+		}
+		if _, ok := paths[tag.Target]; ok {
+			detached = append(detached, tag)
+			continue
+		}
+		if first, ok := firstOf[tag.Target]; ok {
+			tag.Target = first
+			detached = append(detached, tag)
+			continue
+		}
+		message("warning: " + tag.Path + " is restored as a file which is not linked to " + tag.Target + " out of the paths")
+		firstOf[tag.Target] = tag.Path
+		tag.Type = ""
+		tag.Target = ""
+		detached = append(detached, tag)
+	}
+	return detached
+}
+
+// tagsAfterRestoring returns tags of the self repository after restoring remoteTags in place of localTags.
+// Files of the self repository which are not replaced are left with the restored files in a partial restore.
+func tagsAfterRestoring(localCommit Commit, localTags, remoteTags []Tag) []Tag {
+	replaced := make(map[string]struct{})
+	for _, tag := range localTags {
+		replaced[tag.Path] = struct{}{}
+	}
+	tags := append([]Tag{}, remoteTags...)
+	for _, tag := range localCommit.Tags {
+		if _, ok := replaced[tag.Path]; !ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
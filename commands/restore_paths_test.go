@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestorePaths(t *testing.T) {
+	paths := func(tags []Tag) string {
+		var strs []string
+		for _, tag := range tags {
+			strs = append(strs, tag.Path)
+		}
+		return strings.Join(strs, ",")
+	}
+
+	// func matchRestorePaths(patterns []string, path string) bool
+	t.Run("matchRestorePaths()", func(t *testing.T) {
+		patterns := []string{"photos/2019/**", "./docs/contract.pdf", "music/"}
+		cases := map[string]bool{
+			"photos/2019/a.jpg":     true,
+			"photos/2019/sub/b.jpg": true,
+			"photos/2020/a.jpg":     false,
+			"docs/contract.pdf":     true,
+			"docs/contract.pdf.bak": false,
+			"music/a/b.mp3":         true,
+			"musicbox/a.mp3":        false,
+		}
+		for path, want := range cases {
+			if got := matchRestorePaths(patterns, path); got != want {
+				t.Errorf("matchRestorePaths(%s) = %v, want %v", path, got, want)
+			}
+		}
+	})
+
+	// func selectRestoreTags(tags []Tag, patterns []string) (selected []Tag)
+	t.Run("selectRestoreTags()", func(t *testing.T) {
+		tags := []Tag{
+			{Path: "a/first.txt"},
+			{Path: "b/link.txt", Type: TAG_TYPE_HARDLINK, Target: "a/first.txt"},
+			{Path: "b/c.txt"},
+			{Path: "d/e.txt"},
+		}
+		if got := paths(selectRestoreTags(tags, []string{"b"})); got != "b/link.txt,b/c.txt" {
+			t.Errorf("selectRestoreTags(b) = %s, want files in b", got)
+		}
+		if got := paths(selectRestoreTags(tags, []string{})); got != paths(tags) {
+			t.Errorf("selectRestoreTags() without patterns = %s, want all tags", got)
+		}
+	})
+
+	// func detachHardlinks(tags []Tag) []Tag
+	t.Run("detachHardlinks()", func(t *testing.T) {
+		tags := []Tag{
+			{Path: "a/first.txt"},
+			{Path: "a/link.txt", Type: TAG_TYPE_HARDLINK, Target: "a/first.txt"},
+			{Path: "b/link0.txt", Type: TAG_TYPE_HARDLINK, Target: "c/first.txt"},
+			{Path: "b/link1.txt", Type: TAG_TYPE_HARDLINK, Target: "c/first.txt"},
+		}
+		got := detachHardlinks(tags)
+		if len(got) != 4 || got[1].entryType() != TAG_TYPE_HARDLINK || got[1].Target != "a/first.txt" {
+			t.Fatalf("detachHardlinks() = %v, want a hardlink to a/first.txt kept", got)
+		}
+		if got[2].entryType() != TAG_TYPE_FILE || got[2].Target != "" {
+			t.Errorf("detachHardlinks() return b/link0.txt (%s, %s), want a file", got[2].entryType(), got[2].Target)
+		}
+		if got[3].entryType() != TAG_TYPE_HARDLINK || got[3].Target != "b/link0.txt" {
+			t.Errorf("detachHardlinks() return b/link1.txt (%s, %s), want a hardlink to b/link0.txt", got[3].entryType(), got[3].Target)
+		}
+		if tags[2].entryType() != TAG_TYPE_HARDLINK {
+			t.Errorf("detachHardlinks() changes the argument")
+		}
+	})
+
+	// func selectRestoringTags(localCommit, remoteCommit Commit, paths []string) (localTags []Tag, remoteTags []Tag, err error)
+	//   a file out of the paths is not replaced, even if a hardlink to it is restored
+	t.Run("selectRestoringTags() with a hardlink to a file out of the paths", func(t *testing.T) {
+		hash := hashing("0000000000000000000000000000000000000000000000000000000000000000")
+		localCommit := Commit{Tags: []Tag{
+			{Path: "a/f.txt", Hash: hashing("1111111111111111111111111111111111111111111111111111111111111111")},
+			{Path: "b/hard.txt", Hash: hash},
+		}}
+		remoteCommit := Commit{Tags: []Tag{
+			{Path: "a/f.txt", Hash: hash},
+			{Path: "b/hard.txt", Hash: hash, Type: TAG_TYPE_HARDLINK, Target: "a/f.txt"},
+		}}
+		localTags, remoteTags, err := selectRestoringTags(localCommit, remoteCommit, []string{"b/hard.txt"})
+		if err != nil {
+			t.Fatalf("selectRestoringTags() return error \"%s\", want nil", err)
+		}
+		if paths(localTags) != "b/hard.txt" || paths(remoteTags) != "b/hard.txt" {
+			t.Fatalf("selectRestoringTags() = (%s, %s), want only b/hard.txt", paths(localTags), paths(remoteTags))
+		}
+		if remoteTags[0].entryType() != TAG_TYPE_FILE || remoteTags[0].Hash.String() != hash.String() {
+			t.Errorf("selectRestoringTags() return b/hard.txt as %s, want a file of the blob", remoteTags[0].entryType())
+		}
+	})
+
+	// func stashTagsPartially(tags []Tag) (err error)
+	t.Run("stashTagsPartially()", func(t *testing.T) {
+		var moved, removed []string
+		fileOp = &FileOp{
+			rootDir: func() string {
+				return "/root"
+			},
+			moveFile: func(from, to string) error {
+				moved = append(moved, from)
+				return nil
+			},
+			removeFile: func(path string) error {
+				removed = append(removed, path)
+				return nil
+			},
+			findDirPaths: func(root string) ([]string, error) {
+				t.Errorf("stashTagsPartially() finds all directories")
+				return nil, nil
+			},
+		}
+		err := stashTagsPartially([]Tag{
+			{Path: "photos/2019/a/x.jpg"},
+			{Path: "photos/2019/y.jpg"},
+			{Path: "docs/link", Type: TAG_TYPE_SYMLINK, Target: "contract.pdf"},
+		})
+		if err != nil {
+			t.Fatalf("stashTagsPartially() return error \"%s\", want nil", err)
+		}
+		if strings.Join(moved, ",") != "/root/photos/2019/a/x.jpg,/root/photos/2019/y.jpg" {
+			t.Errorf("stashTagsPartially() moves %v", moved)
+		}
+		want := "/root/docs/link,/root/photos/2019/a,/root/photos/2019,/root/photos,/root/docs"
+		if strings.Join(removed, ",") != want {
+			t.Errorf("stashTagsPartially() removes %v, want %s", removed, want)
+		}
+	})
+
+	// func tagsAfterRestoring(localCommit Commit, localTags, remoteTags []Tag) []Tag
+	t.Run("tagsAfterRestoring()", func(t *testing.T) {
+		localCommit := Commit{Tags: []Tag{
+			{Path: "Docs/contract.pdf"},
+			{Path: "photos/a.jpg"},
+		}}
+		remoteCommit := Commit{Tags: []Tag{
+			{Path: "docs"},
+			{Path: "photos/a.jpg"},
+		}}
+		paths := []string{"docs", "photos"}
+		localTags, remoteTags, err := selectRestoringTags(localCommit, remoteCommit, paths)
+		if err != nil {
+			t.Fatalf("selectRestoringTags() return error \"%s\", want nil", err)
+		}
+		got := tagsAfterRestoring(localCommit, localTags, remoteTags)
+		var gotPaths []string
+		for _, tag := range got {
+			gotPaths = append(gotPaths, tag.Path)
+		}
+		if strings.Join(gotPaths, ",") != "docs,photos/a.jpg,Docs/contract.pdf" {
+			t.Errorf("tagsAfterRestoring() = %v", gotPaths)
+		}
+
+		// the file restored as "docs" collides with the local directory "Docs" which is left
+		fileOp = &FileOp{
+			rootDir: func() string { return "/root" },
+			probeFolding: func(dir string) (FilesystemFolding, error) {
+				return FilesystemFolding{Case: true}, nil
+			},
+		}
+		if checkPathCollisions(remoteCommit.Tags) != nil {
+			t.Errorf("checkPathCollisions() of the commit return an error, want nil")
+		}
+		if checkPathCollisions(got) == nil {
+			t.Errorf("checkPathCollisions() of files after restoring return nil, want an error")
+		}
+
+		// all local files are replaced without paths
+		localTags, remoteTags, _ = selectRestoringTags(localCommit, remoteCommit, nil)
+		if got := tagsAfterRestoring(localCommit, localTags, remoteTags); len(got) != 2 {
+			t.Errorf("tagsAfterRestoring() without paths = %v, want tags of the commit", got)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -12,6 +13,8 @@ type RestoreRequest struct {
 	ValidDays  int32
 	Commit     Commit
 	Blobs      []string
+	Tier       string   // the retrieval tier. a request without '#tier:' line is sent with the Bulk tier
+	Paths      []string // patterns of paths to restore partially. all files are restored if it is empty
 }
 
 func (r RestoreRequest) String() (str string) {
@@ -28,10 +31,30 @@ func (r RestoreRequest) Strings() []string {
 		"#repo:" + r.Repository.String(),
 		"#commit:" + r.Commit.Id,
 	}
+	if r.Tier != "" && r.Tier != RESTORE_TIER_BULK {
+		strs = append(strs, "#tier:"+r.Tier)
+	}
+	for _, path := range r.Paths {
+		strs = append(strs, "#path:"+path)
+	}
 	return append(strs, r.Blobs...)
 }
 
 func strs2restoreRequest(lines []string) (RestoreRequest, error) {
+	request, err := strs2restoreRequestHeader(lines)
+	if err != nil {
+		return RestoreRequest{}, err
+	}
+	request.Commit, err = request.Repository.LoadCommit(request.Commit.Id)
+	if err != nil {
+		return RestoreRequest{}, err
+	}
+	return request, nil
+}
+
+// strs2restoreRequestHeader parses a restore request without loading the commit from the repository.
+// Only the id of the commit is set.
+func strs2restoreRequestHeader(lines []string) (RestoreRequest, error) {
 	if len(lines) <= 4 {
 		return RestoreRequest{}, errors.New("The number of lines is small")
 	}
@@ -61,17 +84,36 @@ func strs2restoreRequest(lines []string) (RestoreRequest, error) {
 	}
 
 	// line 3
-	if !strings.HasPrefix(lines[3], "#commit:") || len(lines[3]) != len("#commit:")+8+1+64 {
+	if !strings.HasPrefix(lines[3], "#commit:") || !isCommitId(lines[3][len("#commit:"):]) {
 		return RestoreRequest{}, errors.New("The line 3 is invalid syntax")
 	}
-	commit, err := repo.LoadCommit(lines[3][len("#commit:"):])
-	if err != nil {
-		return RestoreRequest{}, err
+	commit := Commit{Id: lines[3][len("#commit:"):]}
+
+	// optional lines
+	blobs := lines[4:]
+	tier := RESTORE_TIER_BULK
+	var paths []string
+	for len(blobs) > 0 && strings.HasPrefix(blobs[0], "#") {
+		switch {
+		case strings.HasPrefix(blobs[0], "#tier:"):
+			tier, err = findRestoreTier(blobs[0][len("#tier:"):])
+			if err != nil {
+				return RestoreRequest{}, err
+			}
+		case strings.HasPrefix(blobs[0], "#path:"):
+			paths = append(paths, blobs[0][len("#path:"):])
+		default:
+			return RestoreRequest{}, errors.New("Unknown header line '" + blobs[0] + "' is found")
+		}
+		blobs = blobs[1:]
+	}
+	if len(blobs) == 0 {
+		return RestoreRequest{}, errors.New("No blobs are requested")
 	}
 
 	// other lines
-	for _, line := range lines[4:] {
-		if len(line) != 64 {
+	for _, line := range blobs {
+		if _, err := hex2hash(line); err != nil {
 			return RestoreRequest{}, errors.New("Invalid syntax line is found")
 		}
 	}
@@ -79,6 +121,65 @@ func strs2restoreRequest(lines []string) (RestoreRequest, error) {
 		Repository: repo,
 		ValidDays:  validDays,
 		Commit:     commit,
-		Blobs:      lines[4:],
+		Blobs:      blobs,
+		Tier:       tier,
+		Paths:      paths,
 	}, nil
 }
+
+// restoreRequestTime returns the unix time when the restore request was sent. The id of a restore request is the timestamp.
+func restoreRequestTime(restoreRequestId string) (int64, error) {
+	return str2timestamp(restoreRequestId)
+}
+
+// expiry returns the latest unix time when restored copies of the request are deleted.
+// Copies are available for ValidDays after the restore with the tier completes.
+func (r RestoreRequest) expiry(requested int64) int64 {
+	tier := r.Tier
+	if tier == "" {
+		tier = RESTORE_TIER_BULK
+	}
+	return requested + int64(restoreCompletion(tier).Seconds()) + int64(r.ValidDays)*24*60*60
+}
+
+const (
+	RESTORE_STATUS_ONGOING      = "ongoing"      // the restore is in progress
+	RESTORE_STATUS_AVAILABLE    = "available"    // the restored copy can be downloaded
+	RESTORE_STATUS_ARCHIVED     = "archived"     // the restore is not requested, or the restored copy has expired
+	RESTORE_STATUS_NOT_ARCHIVED = "not-archived" // the object can be downloaded without restore
+)
+
+func restoreStatus(object S3Object) string {
+	switch {
+	case !storageClassPrices[object.StorageClass].Archived:
+		return RESTORE_STATUS_NOT_ARCHIVED
+	case object.Restoring:
+		return RESTORE_STATUS_ONGOING
+	case object.Restored:
+		return RESTORE_STATUS_AVAILABLE
+	}
+	return RESTORE_STATUS_ARCHIVED
+}
+
+// LoadRestoreRequestIds returns ids of restore requests in the order of requests
+func (r Repository) LoadRestoreRequestIds() ([]string, error) {
+	exist, err := r.Location.isExist(".arciv/restore-request")
+	if err != nil || !exist {
+		return []string{}, err
+	}
+	ids, err := r.Location.findFilePaths(".arciv/restore-request")
+	if err != nil {
+		return []string{}, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// LoadRestoreRequestHeader loads the restore request without loading the commit from the repository
+func (r Repository) LoadRestoreRequestHeader(restoreRequestId string) (RestoreRequest, error) {
+	lines, err := r.Location.loadLines(".arciv/restore-request/" + restoreRequestId)
+	if err != nil {
+		return RestoreRequest{}, err
+	}
+	return strs2restoreRequestHeader(lines)
+}
@@ -1,3 +1,94 @@
 package commands
 
-// FIXME: Please write tests
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestoreRequest(t *testing.T) {
+	blob := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	commitId := "00001234-" + blob
+	lines := []string{
+		"#arciv-restore-request",
+		"#valid-days:3",
+		"#repo:name:repo-s3 type:s3 region:region-name bucket:bucket-name",
+		"#commit:" + commitId,
+		blob,
+	}
+
+	// func strs2restoreRequestHeader(lines []string) (RestoreRequest, error)
+	t.Run("strs2restoreRequestHeader()", func(t *testing.T) {
+		got, err := strs2restoreRequestHeader(lines)
+		if err != nil {
+			t.Fatalf("strs2restoreRequestHeader() return error \"%s\", want nil", err)
+		}
+		if got.ValidDays != 3 || got.Repository.Name != "repo-s3" || got.Commit.Id != commitId || len(got.Blobs) != 1 || got.Blobs[0] != blob {
+			t.Errorf("strs2restoreRequestHeader() = %+v", got)
+		}
+		if got.String() != "#arciv-restore-request\n#valid-days:3\n#repo:name:repo-s3 type:s3 region:region-name bucket:bucket-name\n#commit:"+commitId+"\n"+blob+"\n" {
+			t.Errorf("RestoreRequest.String() does not return the parsed lines, %q", got.String())
+		}
+		for i := range lines {
+			broken := append([]string{}, lines...)
+			broken[i] = "invalid"
+			if _, err := strs2restoreRequestHeader(broken); err == nil {
+				t.Errorf("strs2restoreRequestHeader() with the invalid line %d return nil, want error", i)
+			}
+		}
+	})
+
+	t.Run("strs2restoreRequestHeader() with optional lines", func(t *testing.T) {
+		withTier := append(append(append([]string{}, lines[:4]...), "#tier:Standard"), lines[4:]...)
+		got, err := strs2restoreRequestHeader(withTier)
+		if err != nil || got.Tier != RESTORE_TIER_STANDARD || len(got.Blobs) != 1 {
+			t.Errorf("strs2restoreRequestHeader() = (%+v, %v), want the tier Standard", got, err)
+		}
+		if strings.Join(got.Strings(), "\n") != strings.Join(withTier, "\n") {
+			t.Errorf("RestoreRequest.Strings() = %v, want %v", got.Strings(), withTier)
+		}
+		withPaths := append(append(append([]string{}, lines[:4]...), "#path:photos/2019/**", "#path:docs/contract.pdf"), lines[4:]...)
+		got, err = strs2restoreRequestHeader(withPaths)
+		if err != nil || strings.Join(got.Paths, ",") != "photos/2019/**,docs/contract.pdf" || strings.Join(got.Strings(), "\n") != strings.Join(withPaths, "\n") {
+			t.Errorf("strs2restoreRequestHeader() = (%+v, %v), want the paths", got, err)
+		}
+		got, err = strs2restoreRequestHeader(lines)
+		if err != nil || got.Tier != RESTORE_TIER_BULK {
+			t.Errorf("strs2restoreRequestHeader() without a tier = (%+v, %v), want the tier Bulk", got, err)
+		}
+		// 12 hours to complete with Standard (DEEP_ARCHIVE)
+		if got.Tier = RESTORE_TIER_STANDARD; got.expiry(0) != 12*60*60+3*24*60*60 {
+			t.Errorf("RestoreRequest.expiry() with Standard = %d, want 12 hours and 3 days", got.expiry(0))
+		}
+	})
+
+	// func (r RestoreRequest) expiry(requested int64) int64
+	t.Run("RestoreRequest.expiry()", func(t *testing.T) {
+		requested, err := restoreRequestTime("00001234")
+		if err != nil || requested != 0x1234 {
+			t.Errorf("restoreRequestTime(\"00001234\") = (%d, %v), want 0x1234", requested, err)
+		}
+		got := RestoreRequest{ValidDays: 3}.expiry(requested)
+		if want := int64(0x1234 + 48*60*60 + 3*24*60*60); got != want {
+			t.Errorf("RestoreRequest.expiry() = %d, want %d", got, want)
+		}
+	})
+
+	// func restoreStatus(object S3Object) string
+	t.Run("restoreStatus()", func(t *testing.T) {
+		cases := []struct {
+			object S3Object
+			want   string
+		}{
+			{S3Object{StorageClass: "DEEP_ARCHIVE"}, RESTORE_STATUS_ARCHIVED},
+			{S3Object{StorageClass: "DEEP_ARCHIVE", Restoring: true}, RESTORE_STATUS_ONGOING},
+			{S3Object{StorageClass: "GLACIER", Restored: true}, RESTORE_STATUS_AVAILABLE},
+			{S3Object{StorageClass: "GLACIER_IR"}, RESTORE_STATUS_NOT_ARCHIVED},
+			{S3Object{StorageClass: "STANDARD"}, RESTORE_STATUS_NOT_ARCHIVED},
+		}
+		for _, c := range cases {
+			if got := restoreStatus(c.object); got != c.want {
+				t.Errorf("restoreStatus(%+v) = %s, want %s", c.object, got, c.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retrieval tiers of restoring archived objects in AWS S3
+const (
+	RESTORE_TIER_EXPEDITED = "Expedited"
+	RESTORE_TIER_STANDARD  = "Standard"
+	RESTORE_TIER_BULK      = "Bulk"
+)
+
+var restoreTiers = []string{RESTORE_TIER_EXPEDITED, RESTORE_TIER_STANDARD, RESTORE_TIER_BULK}
+
+// RestoreTierPrice is the approximate price and time of restoring an archived object with a tier in us-east-1 (USD)
+type RestoreTierPrice struct {
+	Retrieval  float64       // per GB
+	Request    float64       // per 1000 restore requests
+	Completion time.Duration // the max time to complete
+	Window     string        // the typical time to complete
+}
+
+// restoreTierPrices is the price of each archived storage class and tier. A tier which is not listed is not available for the class.
+var restoreTierPrices = map[string]map[string]RestoreTierPrice{
+	"GLACIER": {
+		RESTORE_TIER_EXPEDITED: {Retrieval: 0.03, Request: 10, Completion: 5 * time.Minute, Window: "1-5 minutes"},
+		RESTORE_TIER_STANDARD:  {Retrieval: 0.01, Request: 0.05, Completion: 5 * time.Hour, Window: "3-5 hours"},
+		RESTORE_TIER_BULK:      {Retrieval: 0, Request: 0.025, Completion: 12 * time.Hour, Window: "5-12 hours"},
+	},
+	"DEEP_ARCHIVE": {
+		RESTORE_TIER_STANDARD: {Retrieval: 0.02, Request: 0.1, Completion: 12 * time.Hour, Window: "within 12 hours"},
+		RESTORE_TIER_BULK:     {Retrieval: 0.0025, Request: 0.025, Completion: 48 * time.Hour, Window: "within 48 hours"},
+	},
+}
+
+func findRestoreTier(tier string) (string, error) {
+	for _, t := range restoreTiers {
+		if strings.EqualFold(t, tier) {
+			return t, nil
+		}
+	}
+	return "", errors.New("The retrieval tier '" + tier + "' is unknown. Choose from " + strings.Join(restoreTiers, ", "))
+}
+
+func findRestoreTierPrice(class, tier string) (RestoreTierPrice, error) {
+	price, ok := restoreTierPrices[class][tier]
+	if !ok {
+		return RestoreTierPrice{}, errors.New("The retrieval tier " + tier + " is not available for " + class)
+	}
+	return price, nil
+}
+
+// restoreCompletion returns the max time to complete restores of the tier for any storage class
+func restoreCompletion(tier string) (completion time.Duration) {
+	for _, prices := range restoreTierPrices {
+		if price, ok := prices[tier]; ok && price.Completion > completion {
+			completion = price.Completion
+		}
+	}
+	return completion
+}
+
+// RestoreEstimate is the estimated cost and time of a restore request
+type RestoreEstimate struct {
+	Objects int   // archived objects to restore
+	Bytes   int64 // the total size of archived objects to restore
+	Skipped int   // objects which are not archived, or already restored
+	Cost    float64
+	Window  string // the typical time of the slowest storage class
+}
+
+// estimateRestore estimates the cost and the time to restore objects with the tier
+func estimateRestore(objects []S3Object, tier string) (RestoreEstimate, error) {
+	var estimate RestoreEstimate
+	var completion time.Duration
+	for _, object := range objects {
+		if restoreStatus(object) != RESTORE_STATUS_ARCHIVED {
+			estimate.Skipped++
+			continue
+		}
+		price, err := findRestoreTierPrice(object.StorageClass, tier)
+		if err != nil {
+			return RestoreEstimate{}, err
+		}
+		estimate.Objects++
+		estimate.Bytes += object.Size
+		estimate.Cost += float64(object.Size)/(1024*1024*1024)*price.Retrieval + price.Request/1000
+		if price.Completion > completion {
+			completion = price.Completion
+			estimate.Window = price.Window
+		}
+	}
+	return estimate, nil
+}
+
+func (estimate RestoreEstimate) String() string {
+	str := "restoring " + strconv.Itoa(estimate.Objects) + " archived blobs, " + size2string(estimate.Bytes) + ", estimated cost: " + dollar2string(estimate.Cost)
+	if estimate.Window != "" {
+		str += ", completion: " + estimate.Window
+	}
+	if estimate.Skipped > 0 {
+		str += " (" + strconv.Itoa(estimate.Skipped) + " blobs need no restore)"
+	}
+	return str
+}
+
+// previewRestoreRequest prints the estimated cost and time of restoring blobs of tags with the tier.
+// It returns false if the cost is larger than the config 'restore-confirmation-cost' and it is not confirmed.
+func previewRestoreRequest(location RepositoryLocationS3, tags []Tag, tier string, confirmed bool) (bool, error) {
+	var objects []S3Object
+	var blobs []string
+	for _, tag := range tags {
+		if isIncluded(blobs, tag.Hash.String()) {
+			continue
+		}
+		blobs = append(blobs, tag.Hash.String())
+		object, err := s3Op.headObject(location.RegionName, location.BucketName, ".arciv/blob/"+tag.Hash.String())
+		if err != nil {
+			return false, err
+		}
+		objects = append(objects, object)
+	}
+	estimate, err := estimateRestore(objects, tier)
+	if err != nil {
+		return false, err
+	}
+	message(estimate.String() + " (approximate prices of us-east-1, tier: " + tier + ")")
+
+	threshold, err := strconv.ParseFloat(configValue("restore-confirmation-cost"), 64)
+	if err != nil {
+		return false, errors.New("The config restore-confirmation-cost must be a number of USD")
+	}
+	if confirmed || estimate.Cost <= threshold {
+		return true, nil
+	}
+	return confirm("The estimated cost is more than " + dollar2string(threshold) + ". Send the restore request?"), nil
+}
+
+// confirm asks the question and returns true if the answer from stdin is yes
+func confirm(question string) bool {
+	message(question + " [y/N]")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRestoreTier(t *testing.T) {
+	// func findRestoreTier(tier string) (string, error)
+	t.Run("findRestoreTier()", func(t *testing.T) {
+		for _, tier := range []string{"bulk", "Standard", "EXPEDITED"} {
+			got, err := findRestoreTier(tier)
+			if err != nil || !strings.EqualFold(got, tier) {
+				t.Errorf("findRestoreTier(%s) = (%s, %v), want the tier", tier, got, err)
+			}
+		}
+		if _, err := findRestoreTier("Fast"); err == nil {
+			t.Errorf("findRestoreTier(\"Fast\") return nil, want error")
+		}
+	})
+
+	// func estimateRestore(objects []S3Object, tier string) (RestoreEstimate, error)
+	t.Run("estimateRestore()", func(t *testing.T) {
+		const gb = 1024 * 1024 * 1024
+		objects := []S3Object{
+			{StorageClass: "DEEP_ARCHIVE", Size: 10 * gb},
+			{StorageClass: "GLACIER", Size: 2 * gb},
+			{StorageClass: "DEEP_ARCHIVE", Size: gb, Restored: true},
+			{StorageClass: "GLACIER_IR", Size: gb},
+		}
+		got, err := estimateRestore(objects, RESTORE_TIER_STANDARD)
+		if err != nil {
+			t.Fatalf("estimateRestore() return error \"%s\", want nil", err)
+		}
+		wantCost := 10*0.02 + 0.1/1000 + 2*0.01 + 0.05/1000
+		if got.Objects != 2 || got.Bytes != 12*gb || got.Skipped != 2 || math.Abs(got.Cost-wantCost) > 1e-9 || got.Window != "within 12 hours" {
+			t.Errorf("estimateRestore() = %+v, want 2 objects, 12GB, 2 skipped, $%f, within 12 hours", got, wantCost)
+		}
+
+		// Expedited is not available for DEEP_ARCHIVE
+		if _, err := estimateRestore(objects, RESTORE_TIER_EXPEDITED); err == nil {
+			t.Errorf("estimateRestore() with Expedited for DEEP_ARCHIVE return nil, want error")
+		}
+		if _, err := estimateRestore(objects[1:], RESTORE_TIER_EXPEDITED); err != nil {
+			t.Errorf("estimateRestore() with Expedited for GLACIER return error \"%s\", want nil", err)
+		}
+	})
+}
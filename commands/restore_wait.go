@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"strconv"
+	"time"
+)
+
+// Intervals of polling the restore status. The interval is doubled while no blob becomes available.
+const RESTORE_POLL_MIN = 5 * time.Minute
+const RESTORE_POLL_MAX = 60 * time.Minute
+
+var sleepFor func(time.Duration)
+
+func init() {
+	sleepFor = time.Sleep
+}
+
+// requestedTags returns tags of the commit of the request for each requested blob
+func requestedTags(request RestoreRequest) (tags []Tag) {
+	for _, tag := range blobTags(request.Commit.Tags) {
+		if isIncluded(request.Blobs, tag.Hash.String()) && !isIncluded(tagHashes(tags), tag.Hash.String()) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func tagHashes(tags []Tag) (hashes []string) {
+	for _, tag := range tags {
+		hashes = append(hashes, tag.Hash.String())
+	}
+	return hashes
+}
+
+// waitForRestoredBlobs polls the restore status of requested blobs, and downloads each blob to .arciv/blob as soon as it becomes available.
+// Downloaded blobs are kept in .arciv/blob, so waiting can be resumed with the restore request after an interruption.
+// Blobs whose restored copies have expired before downloading are requested again.
+func waitForRestoredBlobs(request RestoreRequest) error {
+	location, ok := request.Repository.Location.(RepositoryLocationS3)
+	if !ok {
+		// blobs of other repositories can be downloaded without restore
+		return nil
+	}
+	tags := requestedTags(request)
+	interval := RESTORE_POLL_MIN
+	for {
+		localBlobs, err := SelfRepo().FetchBlobHashes()
+		if err != nil {
+			return err
+		}
+		var pending []Tag
+		for _, tag := range tags {
+			if !isIncluded(localBlobs, tag.Hash.String()) {
+				pending = append(pending, tag)
+			}
+		}
+		if len(pending) == 0 {
+			message("All requested blobs are downloaded")
+			return nil
+		}
+
+		var available []Tag
+		var archived []Tag
+		for _, tag := range pending {
+			object, err := s3Op.headObject(location.RegionName, location.BucketName, ".arciv/blob/"+tag.Hash.String())
+			if err != nil {
+				return err
+			}
+			switch restoreStatus(object) {
+			case RESTORE_STATUS_AVAILABLE, RESTORE_STATUS_NOT_ARCHIVED:
+				available = append(available, tag)
+			case RESTORE_STATUS_ARCHIVED:
+				archived = append(archived, tag)
+			}
+		}
+		if len(archived) > 0 {
+			message(strconv.Itoa(len(archived)) + " blobs are not restored (the restored copies have expired, or the request was not sent). Requesting again")
+			_, err = request.Repository.ReceiveRemoteBlobsRequest(archived, request.ValidDays, request.Tier)
+			if err != nil {
+				return err
+			}
+		}
+		for _, tag := range available {
+			err = request.Repository.ReceiveRemoteBlobs([]Tag{tag})
+			if err != nil {
+				return err
+			}
+			err = checkReceivedBlobSizes([]Tag{tag})
+			if err != nil {
+				return err
+			}
+		}
+		if len(available) > 0 {
+			interval = RESTORE_POLL_MIN
+			continue
+		}
+
+		message(strconv.Itoa(len(pending)) + " of " + strconv.Itoa(len(tags)) + " blobs are not available yet. Check again in " + interval.String())
+		sleepFor(interval)
+		interval *= 2
+		if interval > RESTORE_POLL_MAX {
+			interval = RESTORE_POLL_MAX
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoreWait(t *testing.T) {
+	// func waitForRestoredBlobs(request RestoreRequest) error
+	t.Run("waitForRestoredBlobs()", func(t *testing.T) {
+		blob := func(c string) Hash {
+			h, _ := hex2hash(strings.Repeat(c, 64))
+			return h
+		}
+		request := RestoreRequest{
+			Repository: Repository{Name: "repo-s3", Location: RepositoryLocationS3{RegionName: "region", BucketName: "bucket"}},
+			ValidDays:  3,
+			Commit: Commit{Tags: []Tag{
+				{Path: "a.txt", Hash: blob("a")},
+				{Path: "b.txt", Hash: blob("b")},
+				{Path: "c.txt", Hash: blob("c")},
+				{Path: "copy-of-c.txt", Hash: blob("c")},
+			}},
+			Blobs: []string{blob("a").String(), blob("b").String(), blob("c").String()},
+		}
+
+		var sleeps []time.Duration
+		var downloaded []string
+		var requested []string
+		sleepFor = func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		}
+		fileOp = &FileOp{
+			rootDir: func() string {
+				return "/root"
+			},
+			findFilePaths: func(root string) ([]string, error) {
+				// "a" was downloaded before an interruption
+				return append([]string{blob("a").String(), blob("a").String() + ".download"}, downloaded...), nil
+			},
+		}
+		s3Op = &S3Op{
+			headObject: func(region string, bucket string, name string) (S3Object, error) {
+				switch name[len(".arciv/blob/"):] {
+				case blob("b").String():
+					// available after 2 polls
+					return S3Object{StorageClass: "DEEP_ARCHIVE", Restoring: len(sleeps) < 2, Restored: len(sleeps) >= 2}, nil
+				case blob("c").String():
+					// the restored copy has expired, and it is available after requested again and 3 polls
+					return S3Object{StorageClass: "DEEP_ARCHIVE", Restoring: len(requested) > 0 && len(sleeps) < 3, Restored: len(sleeps) >= 3}, nil
+				}
+				t.Errorf("s3Op.headObject() gets the downloaded blob %s", name)
+				return S3Object{}, nil
+			},
+			receiveBlobs: func(region string, bucket string, paths, names []string) error {
+				for _, name := range names {
+					downloaded = append(downloaded, name[len(".arciv/blob/"):])
+				}
+				return nil
+			},
+			receiveBlobsRequest: func(region string, bucket string, names []string, validDays int32, tier string) ([]string, error) {
+				requested = append(requested, names...)
+				return names, nil
+			},
+		}
+
+		err := waitForRestoredBlobs(request)
+		if err != nil {
+			t.Fatalf("waitForRestoredBlobs() return error \"%s\", want nil", err)
+		}
+		if strings.Join(downloaded, ",") != blob("b").String()+","+blob("c").String() {
+			t.Errorf("waitForRestoredBlobs() downloads %v, want [b c]", downloaded)
+		}
+		if len(requested) != 1 || requested[0] != ".arciv/blob/"+blob("c").String() {
+			t.Errorf("waitForRestoredBlobs() requests %v again, want [c]", requested)
+		}
+		// the interval is reset after a blob is downloaded
+		want := []time.Duration{RESTORE_POLL_MIN, 2 * RESTORE_POLL_MIN, RESTORE_POLL_MIN}
+		if len(sleeps) != len(want) || sleeps[0] != want[0] || sleeps[1] != want[1] || sleeps[2] != want[2] {
+			t.Errorf("waitForRestoredBlobs() sleeps %v, want %v", sleeps, want)
+		}
+	})
+}
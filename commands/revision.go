@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A revision refers a commit of a timeline like a revision of git.
+//
+//	latest          the latest commit
+//	<alias>         a commit id, a part of it or a label
+//	@{<date>}       the last commit at or before the date. A date without time means the end of the day
+//	<base>~<n>      the n-th commit before the base in the timeline. '~' means '~1'
+//	<base>^         the commit before the base in the timeline
+//	<repository>:<revision>
+//	                the revision in the repository instead of the repository of the command
+const REVISION_LATEST = "latest"
+
+var revisionDateLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// splitRepositoryRevision splits "<repository>:<revision>". The repository name is empty if the revision is not qualified
+func splitRepositoryRevision(revision string) (repoName string, rest string) {
+	idx := strings.Index(revision, ":")
+	// a date of "@{...}" may include ':'
+	if idx == -1 || (strings.Contains(revision, "@{") && strings.Index(revision, "@{") < idx) {
+		return "", revision
+	}
+	return revision[:idx], revision[idx+1:]
+}
+
+// splitRevision splits a revision into the base and the number of commits to go back with '~' and '^'
+func splitRevision(revision string) (base string, back int, err error) {
+	idx := strings.IndexAny(revision, "~^")
+	if idx == -1 {
+		return revision, 0, nil
+	}
+	base, suffix := revision[:idx], revision[idx:]
+	for len(suffix) > 0 {
+		c := suffix[0]
+		suffix = suffix[1:]
+		if c == '^' {
+			back++
+			continue
+		}
+		if c != '~' {
+			return "", 0, errors.New("The revision '" + revision + "' is invalid")
+		}
+		digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+		if digits == 0 {
+			back++
+			continue
+		}
+		n, err := strconv.Atoi(suffix[:digits])
+		if err != nil {
+			return "", 0, err
+		}
+		back += n
+		suffix = suffix[digits:]
+	}
+	return base, back, nil
+}
+
+// parseRevisionDate returns the unix time which "@{<date>}" refers in the local time zone
+func parseRevisionDate(date string) (int64, error) {
+	for i, layout := range revisionDateLayouts {
+		t, err := time.ParseInLocation(layout, date, time.Local)
+		if err != nil {
+			continue
+		}
+		if i == len(revisionDateLayouts)-1 {
+			// the end of the day
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return t.Unix(), nil
+	}
+	return 0, errors.New("The date '" + date + "' is invalid. It must be like 2006-01-02, 2006-01-02T15:04 or 2006-01-02T15:04:05")
+}
+
+// findRevision returns the commit id of the timeline which the revision refers.
+// labels are used if the base of the revision is a label name.
+func findRevision(revision string, timeline []string, labels []Label) (string, error) {
+	base, back, err := splitRevision(revision)
+	if err != nil {
+		return "", err
+	}
+	if len(timeline) == 0 {
+		return "", errors.New("Commit does not exists")
+	}
+	var idx int
+	switch {
+	case base == REVISION_LATEST:
+		idx = len(timeline) - 1
+	case strings.HasPrefix(base, "@{") && strings.HasSuffix(base, "}"):
+		at, err := parseRevisionDate(base[2 : len(base)-1])
+		if err != nil {
+			return "", err
+		}
+		// the newest commit at or before the date. the timeline is not always ordered by timestamps (ex. a commit appended by restore)
+		idx = -1
+		var newest int64
+		for i, commitId := range timeline {
+			timestamp, _, err := parseCommitId(commitId)
+			if err != nil {
+				return "", err
+			}
+			if timestamp <= at && (idx == -1 || timestamp >= newest) {
+				idx, newest = i, timestamp
+			}
+		}
+		if idx == -1 {
+			return "", errors.New("No commit exists at or before " + base[2:len(base)-1])
+		}
+	default:
+		commitId, err := findCommitId(resolveLabel(base, labels), timeline)
+		if err != nil {
+			return "", err
+		}
+		idx = indexOf(timeline, commitId)
+	}
+	if idx-back < 0 {
+		return "", errors.New("The revision '" + revision + "' is before the first commit")
+	}
+	return timeline[idx-back], nil
+}
+
+// revisionLabels loads labels of the repository only if the revision may refer a label
+func (repository Repository) revisionLabels(revision string) ([]Label, error) {
+	base, _, err := splitRevision(revision)
+	if err != nil || !isLabelName(base) {
+		return []Label{}, err
+	}
+	return repository.LoadLabels()
+}
+
+// loadRevision loads the commit which the revision refers.
+// The repository is replaced if the revision is qualified with a repository name.
+func loadRevision(repository Repository, revision string) (Repository, Commit, error) {
+	repoName, rest := splitRepositoryRevision(revision)
+	if repoName != "" && repoName != repository.Name {
+		repo, err := findRepo(repoName)
+		if err != nil {
+			return Repository{}, Commit{}, err
+		}
+		repository = repo
+	}
+	commit, err := repository.LoadCommitFromAlias(rest)
+	if err != nil {
+		return Repository{}, Commit{}, err
+	}
+	return repository, commit, nil
+}
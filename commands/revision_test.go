@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevision(t *testing.T) {
+	day := func(date string, hour int) string {
+		tm, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return timestamp2string(tm.Add(time.Duration(hour) * time.Hour).Unix())
+	}
+	timeline := []string{
+		day("2023-02-27", 10) + "-0000000000000000000000000000000000000000000000000000000000000000",
+		day("2023-03-01", 9) + "-1111111111111111111111111111111111111111111111111111111111111111",
+		day("2023-03-01", 23) + "-2222222222222222222222222222222222222222222222222222222222222222",
+		day("2023-03-05", 0) + "-3333333333333333333333333333333333333333333333333333333333333333",
+	}
+	labels := []Label{{Name: "before-reorg", CommitId: timeline[2]}}
+
+	// func findRevision(revision string, timeline []string, labels []Label) (string, error)
+	t.Run("findRevision()", func(t *testing.T) {
+		cases := map[string]string{
+			"latest":              timeline[3],
+			"latest~3":            timeline[0],
+			"latest~":             timeline[2],
+			"latest^^":            timeline[1],
+			"latest~1^":           timeline[1],
+			"2222":                timeline[2],
+			"before-reorg":        timeline[2],
+			"before-reorg^":       timeline[1],
+			"@{2023-03-01}":       timeline[2],
+			"@{2023-03-01T12:00}": timeline[1],
+			"@{2023-03-04}":       timeline[2],
+			"@{2023-03-01}~2":     timeline[0],
+			"@{2030-01-01}":       timeline[3],
+		}
+		for revision, want := range cases {
+			got, err := findRevision(revision, timeline, labels)
+			if err != nil || got != want {
+				t.Errorf("findRevision(%s) = (%s, %v), want %s", revision, got, err, want)
+			}
+		}
+		for _, revision := range []string{"latest~4", "@{2023-02-26}", "@{March 1st}", "latest~x", "after-reorg", ""} {
+			if got, err := findRevision(revision, timeline, labels); err == nil {
+				t.Errorf("findRevision(%s) = %s, want an error", revision, got)
+			}
+		}
+		// an older commit appended to the end of the timeline (ex. by restore) is not chosen over a newer commit
+		appended := append(append([]string{}, timeline...), day("2023-02-28", 0)+"-4444444444444444444444444444444444444444444444444444444444444444")
+		if got, err := findRevision("@{2023-03-04}", appended, labels); err != nil || got != timeline[2] {
+			t.Errorf("findRevision(@{2023-03-04}) = (%s, %v), want %s", got, err, timeline[2])
+		}
+		if got, err := findRevision("@{2023-02-28T12:00}", appended, labels); err != nil || got != appended[4] {
+			t.Errorf("findRevision(@{2023-02-28T12:00}) = (%s, %v), want %s", got, err, appended[4])
+		}
+		if _, err := findRevision("latest", []string{}, labels); err == nil {
+			t.Errorf("findRevision(latest) with an empty timeline return nil, want an error")
+		}
+	})
+
+	// func splitRepositoryRevision(revision string) (repoName string, rest string)
+	t.Run("splitRepositoryRevision()", func(t *testing.T) {
+		cases := map[string][2]string{
+			"nas:@{2023-03-01}":       {"nas", "@{2023-03-01}"},
+			"nas:latest~1":            {"nas", "latest~1"},
+			"latest":                  {"", "latest"},
+			"@{2023-03-01T12:00}":     {"", "@{2023-03-01T12:00}"},
+			"nas:@{2023-03-01T12:00}": {"nas", "@{2023-03-01T12:00}"},
+		}
+		for revision, want := range cases {
+			repoName, rest := splitRepositoryRevision(revision)
+			if repoName != want[0] || rest != want[1] {
+				t.Errorf("splitRepositoryRevision(%s) = (%s, %s), want (%s, %s)", revision, repoName, rest, want[0], want[1])
+			}
+		}
+	})
+}
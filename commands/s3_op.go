@@ -1,27 +1,44 @@
 package commands
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 	//  "github.com/aws/aws-sdk-go-v2"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 type S3Op struct {
 	findFilePaths       func(region string, bucket string, root string) (relativePaths []string, err error)
 	writeLines          func(region string, bucket string, path string, lines []string) error
 	loadLines           func(region string, bucket string, path string) ([]string, error)
-	sendBlobs           func(region string, bucket string, paths, names []string) error
+	isExist             func(region string, bucket string, path string) (bool, error)
+	removeFile          func(region string, bucket string, path string) error
+	sendBlobs           func(region string, bucket string, paths, names, storageClasses []string) error
 	receiveBlobs        func(region string, bucket string, paths, names []string) error
-	receiveBlobsRequest func(region string, bucket string, names []string, validDays int32) (namesRequested []string, err error)
+	receiveBlobsRequest func(region string, bucket string, names []string, validDays int32, tier string) (namesRequested []string, err error)
+	openBlob            func(region string, bucket string, name string) (io.ReadCloser, error)
+	writeBlob           func(region string, bucket string, name string, r io.Reader, storageClass string) error
+	headObject          func(region string, bucket string, name string) (S3Object, error)
+	copyObject          func(region string, bucket string, name string, storageClass string, size int64) error
+}
+
+// S3Object is the state of an object in a bucket
+type S3Object struct {
+	StorageClass string // "STANDARD" if it is not set
+	Size         int64
+	LastModified int64
+	Restoring    bool  // a restore request is in progress
+	Restored     bool  // a temporary copy of the archived object is available
+	Expiry       int64 // unix time when the temporary copy is deleted
 }
 
 var s3Op *S3Op
@@ -86,12 +103,36 @@ func (bucketClient S3BucketClient) getLines(key string) (lines []string, err err
 		return []string{}, err
 	}
 	defer got.Body.Close()
-	scanner := bufio.NewScanner(got.Body)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	return readLines(got.Body)
+}
+
+func (bucketClient S3BucketClient) head(key string) (*s3.HeadObjectOutput, error) {
+	return bucketClient.S3client.HeadObject(
+		context.TODO(),
+		&s3.HeadObjectInput{
+			Bucket: &bucketClient.BucketName,
+			Key:    &key,
+		},
+	)
+}
+
+func (bucketClient S3BucketClient) deleteObject(key string) error {
+	_, err := bucketClient.S3client.DeleteObject(
+		context.TODO(),
+		&s3.DeleteObjectInput{
+			Bucket: &bucketClient.BucketName,
+			Key:    &key,
+		},
+	)
+	return err
+}
+
+func isNotFoundError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
-	return lines, nil
+	return apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey"
 }
 
 func (bucketClient S3BucketClient) getFile(key, localPath string) error {
@@ -129,7 +170,7 @@ func (bucketClient S3BucketClient) putLines(key string, lines []string) error {
 	return err
 }
 
-func (bucketClient S3BucketClient) putFile2deepArchive(key, localPath string) error {
+func (bucketClient S3BucketClient) putFile(key, localPath string, storageClass string) error {
 	f, err := os.OpenFile(localPath, os.O_RDONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return err
@@ -143,13 +184,157 @@ func (bucketClient S3BucketClient) putFile2deepArchive(key, localPath string) er
 		Bucket:       &bucketClient.BucketName,
 		Key:          &key,
 		Body:         f,
-		StorageClass: types.StorageClassDeepArchive,
+		StorageClass: types.StorageClass(storageClass),
+	},
+	)
+	return err
+}
+
+// putStream uploads a stream of unknown length with multipart upload.
+// Parts are buffered in memory, so they are uploaded one by one.
+// An upload which fails on the way is aborted and no object is created.
+func (bucketClient S3BucketClient) putStream(key string, r io.Reader, storageClass string) error {
+	uploader := manager.NewUploader(bucketClient.S3client, func(u *manager.Uploader) {
+		u.PartSize = 100 * 1024 * 1024 // 100MB par part
+		u.Concurrency = 1
+	})
+	_, err := uploader.Upload(context.TODO(), &s3.PutObjectInput{
+		Bucket:       &bucketClient.BucketName,
+		Key:          &key,
+		Body:         r,
+		StorageClass: types.StorageClass(storageClass),
 	},
 	)
 	return err
 }
 
-func (bucketClient S3BucketClient) restoreRequest(key string, validDays int32) error {
+func (bucketClient S3BucketClient) getStream(key string) (io.ReadCloser, error) {
+	got, err := bucketClient.S3client.GetObject(
+		context.TODO(),
+		&s3.GetObjectInput{
+			Bucket: &bucketClient.BucketName,
+			Key:    &key,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return got.Body, nil
+}
+
+func (bucketClient S3BucketClient) headObject(key string) (S3Object, error) {
+	got, err := bucketClient.head(key)
+	if err != nil {
+		return S3Object{}, err
+	}
+	object := S3Object{StorageClass: string(got.StorageClass), Size: got.ContentLength}
+	if object.StorageClass == "" {
+		object.StorageClass = string(types.StorageClassStandard)
+	}
+	if got.LastModified != nil {
+		object.LastModified = got.LastModified.Unix()
+	}
+	// ex. 'ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"'
+	if got.Restore != nil {
+		object.Restoring = strings.Contains(*got.Restore, `ongoing-request="true"`)
+		object.Restored = strings.Contains(*got.Restore, `ongoing-request="false"`)
+		if idx := strings.Index(*got.Restore, `expiry-date="`); idx != -1 {
+			expiry := (*got.Restore)[idx+len(`expiry-date="`):]
+			expiry = expiry[:strings.Index(expiry+`"`, `"`)]
+			if t, err := time.Parse(time.RFC1123, expiry); err == nil {
+				object.Expiry = t.Unix()
+			}
+		}
+	}
+	return object, nil
+}
+
+// S3_COPY_OBJECT_MAX is the max size of an object copied with a CopyObject request.
+// A larger object is copied with a multipart upload.
+const S3_COPY_OBJECT_MAX = 5 * 1024 * 1024 * 1024
+const S3_COPY_PART_SIZE = 512 * 1024 * 1024
+
+// copyObject copies the object to itself with the storage class on the server side
+func (bucketClient S3BucketClient) copyObject(key string, storageClass string, size int64) error {
+	source := bucketClient.BucketName + "/" + key
+	if size <= S3_COPY_OBJECT_MAX {
+		_, err := bucketClient.S3client.CopyObject(
+			context.TODO(),
+			&s3.CopyObjectInput{
+				Bucket:            &bucketClient.BucketName,
+				Key:               &key,
+				CopySource:        &source,
+				MetadataDirective: types.MetadataDirectiveCopy,
+				StorageClass:      types.StorageClass(storageClass),
+			},
+		)
+		return err
+	}
+
+	created, err := bucketClient.S3client.CreateMultipartUpload(
+		context.TODO(),
+		&s3.CreateMultipartUploadInput{
+			Bucket:       &bucketClient.BucketName,
+			Key:          &key,
+			StorageClass: types.StorageClass(storageClass),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	err = bucketClient.copyParts(key, source, size, created.UploadId)
+	if err != nil {
+		// parts of the upload are charged until the upload is aborted
+		bucketClient.S3client.AbortMultipartUpload(
+			context.TODO(),
+			&s3.AbortMultipartUploadInput{
+				Bucket:   &bucketClient.BucketName,
+				Key:      &key,
+				UploadId: created.UploadId,
+			},
+		)
+	}
+	return err
+}
+
+// copyParts copies the source object to parts of the multipart upload, and completes the upload
+func (bucketClient S3BucketClient) copyParts(key string, source string, size int64, uploadId *string) error {
+	var parts []types.CompletedPart
+	for offset, number := int64(0), int32(1); offset < size; offset, number = offset+S3_COPY_PART_SIZE, number+1 {
+		last := offset + S3_COPY_PART_SIZE - 1
+		if last >= size {
+			last = size - 1
+		}
+		copyRange := fmt.Sprintf("bytes=%d-%d", offset, last)
+		copied, err := bucketClient.S3client.UploadPartCopy(
+			context.TODO(),
+			&s3.UploadPartCopyInput{
+				Bucket:          &bucketClient.BucketName,
+				Key:             &key,
+				CopySource:      &source,
+				CopySourceRange: &copyRange,
+				PartNumber:      number,
+				UploadId:        uploadId,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, types.CompletedPart{ETag: copied.CopyPartResult.ETag, PartNumber: number})
+	}
+	_, err := bucketClient.S3client.CompleteMultipartUpload(
+		context.TODO(),
+		&s3.CompleteMultipartUploadInput{
+			Bucket:          &bucketClient.BucketName,
+			Key:             &key,
+			UploadId:        uploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		},
+	)
+	return err
+}
+
+func (bucketClient S3BucketClient) restoreRequest(key string, validDays int32, tier string) error {
 	_, err := bucketClient.S3client.RestoreObject(
 		context.TODO(),
 		&s3.RestoreObjectInput{
@@ -158,7 +343,7 @@ func (bucketClient S3BucketClient) restoreRequest(key string, validDays int32) e
 			RestoreRequest: &types.RestoreRequest{
 				Days: validDays,
 				GlacierJobParameters: &types.GlacierJobParameters{
-					Tier: "Bulk",
+					Tier: types.Tier(tier),
 				},
 			},
 		},
@@ -188,16 +373,26 @@ func init() {
 		loadLines: func(region string, bucket string, path string) ([]string, error) {
 			return client(region, bucket).getLines(path)
 		},
-		sendBlobs: func(region string, bucket string, paths, names []string) error {
-			if len(paths) != len(names) {
-				return errors.New("arguments of receiveBlobs() require the same length slice")
+		isExist: func(region string, bucket string, path string) (bool, error) {
+			_, err := client(region, bucket).head(path)
+			if isNotFoundError(err) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		removeFile: func(region string, bucket string, path string) error {
+			return client(region, bucket).deleteObject(path)
+		},
+		sendBlobs: func(region string, bucket string, paths, names, storageClasses []string) error {
+			if len(paths) != len(names) || len(paths) != len(storageClasses) {
+				return errors.New("arguments of sendBlobs() require the same length slice")
 			}
 			for i, path := range paths {
-				err := client(region, bucket).putFile2deepArchive(names[i], path)
+				err := client(region, bucket).putFile(names[i], path, storageClasses[i])
 				if err != nil {
 					return err
 				}
-				message("Uploaded: " + path + " (file) -> " + names[i] + " (s3)")
+				message("Uploaded: " + path + " (file) -> " + names[i] + " (s3, " + storageClasses[i] + ")")
 			}
 			return nil
 		},
@@ -218,14 +413,35 @@ func init() {
 			}
 			return nil
 		},
-		receiveBlobsRequest: func(region string, bucket string, names []string, validDays int32) (namesRequested []string, err error) {
+		receiveBlobsRequest: func(region string, bucket string, names []string, validDays int32, tier string) (namesRequested []string, err error) {
 			for i, name := range names {
-				err := client(region, bucket).restoreRequest(name, validDays)
+				object, err := client(region, bucket).headObject(name)
+				if err != nil {
+					return names[:i], err
+				}
+				if !storageClassPrices[object.StorageClass].Archived {
+					// an object which is not archived can be downloaded without restore
+					message("Restore is not needed: " + name + " (" + object.StorageClass + ")")
+					continue
+				}
+				err = client(region, bucket).restoreRequest(name, validDays, tier)
 				if err != nil {
 					return names[:i], err
 				}
 			}
 			return names, nil
 		},
+		openBlob: func(region string, bucket string, name string) (io.ReadCloser, error) {
+			return client(region, bucket).getStream(name)
+		},
+		writeBlob: func(region string, bucket string, name string, r io.Reader, storageClass string) error {
+			return client(region, bucket).putStream(name, r, storageClass)
+		},
+		headObject: func(region string, bucket string, name string) (S3Object, error) {
+			return client(region, bucket).headObject(name)
+		},
+		copyObject: func(region string, bucket string, name string, storageClass string, size int64) error {
+			return client(region, bucket).copyObject(name, storageClass, size)
+		},
 	}
 }
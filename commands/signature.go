@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+type SignatureStatus int
+
+const (
+	SIGNATURE_VALID SignatureStatus = iota
+	SIGNATURE_UNSIGNED
+	SIGNATURE_UNTRUSTED
+	SIGNATURE_INVALID
+)
+
+func (status SignatureStatus) String() string {
+	switch status {
+	case SIGNATURE_VALID:
+		return "valid"
+	case SIGNATURE_UNSIGNED:
+		return "unsigned"
+	case SIGNATURE_UNTRUSTED:
+		return "untrusted"
+	default:
+		return "invalid"
+	}
+}
+
+// signingKey is loaded from .arciv/signing-key of the self repository.
+// Commits and timelines are written without signatures if it is nil.
+var signingKey ed25519.PrivateKey
+
+var requireSignatureOption bool
+
+func loadSigningKey() {
+	_, err := findRootDir()
+	if err != nil {
+		// not in a repository (ex. arciv init)
+		return
+	}
+	path := arcivDir() + "/signing-key"
+	exist, err := fileOp.isExist(path)
+	if err != nil {
+		Exit(err, 1)
+	}
+	if !exist {
+		return
+	}
+	lines, err := fileOp.loadLines(path)
+	if err != nil {
+		Exit(err, 1)
+	}
+	signingKey, err = strs2signingKey(lines)
+	if err != nil {
+		Exit(err, 1)
+	}
+}
+
+func strs2signingKey(lines []string) (ed25519.PrivateKey, error) {
+	if len(lines) != 2 || lines[0] != "#arciv-signing-key" {
+		return nil, errors.New(".arciv/signing-key is invalid syntax")
+	}
+	seed, err := hex.DecodeString(lines[1])
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New(".arciv/signing-key is invalid syntax")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func signingKey2strs(key ed25519.PrivateKey) []string {
+	return []string{"#arciv-signing-key", hex.EncodeToString(key.Seed())}
+}
+
+func publicKey2string(key ed25519.PublicKey) string {
+	return hex.EncodeToString(key)
+}
+
+func str2publicKey(str string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(str)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("A public key must be 64 hex characters")
+	}
+	return key, nil
+}
+
+// commitSignatureMessage returns the message signed for a commit.
+// Side records of the tag list file (sizes and the commit info) are signed with it, because they are not included in the commit hash.
+func commitSignatureMessage(commitId string, lines []string, sideRecords ...[]string) string {
+	signed := "arciv-commit:" + commitId + "\n" + strings.Join(lines, "\n")
+	for _, record := range sideRecords {
+		if record != nil {
+			signed += "\n" + strings.Join(record, "\n")
+		}
+	}
+	return signed
+}
+
+func timelineSignatureMessage(timeline []string) string {
+	return "arciv-timeline\n" + strings.Join(timeline, "\n")
+}
+
+// labelsSignatureMessage returns the message signed for labels. Removed labels are signed with them.
+func labelsSignatureMessage(lines []string, removed []string) string {
+	signed := "arciv-labels\n" + strings.Join(lines, "\n")
+	if removed != nil {
+		signed += "\n" + strings.Join(removed, "\n")
+	}
+	return signed
+}
+
+// writeSignature writes the signature of the message to path + ".sig" with the local signing key
+func (repository Repository) writeSignature(path string, message string) error {
+	if signingKey == nil {
+		// a signature of the previous content is removed, or the rewritten file looks tampered
+		exist, err := repository.Location.isExist(path + ".sig")
+		if err != nil || !exist {
+			return err
+		}
+		return repository.Location.removeFile(path + ".sig")
+	}
+	signature := ed25519.Sign(signingKey, []byte(message))
+	return repository.Location.writeLines(path+".sig", []string{
+		"#arciv-signature",
+		"#key:" + publicKey2string(signingKey.Public().(ed25519.PublicKey)),
+		"#signature:" + hex.EncodeToString(signature),
+	})
+}
+
+func (repository Repository) verifySignature(path string, message string) (SignatureStatus, error) {
+	exist, err := repository.Location.isExist(path + ".sig")
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	if !exist {
+		return SIGNATURE_UNSIGNED, nil
+	}
+	lines, err := repository.Location.loadLines(path + ".sig")
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	if len(lines) != 3 || lines[0] != "#arciv-signature" || !strings.HasPrefix(lines[1], "#key:") || !strings.HasPrefix(lines[2], "#signature:") {
+		return SIGNATURE_INVALID, nil
+	}
+	key, err := str2publicKey(lines[1][len("#key:"):])
+	if err != nil {
+		return SIGNATURE_INVALID, nil
+	}
+	signature, err := hex.DecodeString(lines[2][len("#signature:"):])
+	if err != nil {
+		return SIGNATURE_INVALID, nil
+	}
+	if !ed25519.Verify(key, []byte(message), signature) {
+		return SIGNATURE_INVALID, nil
+	}
+	trustedKeys, err := repository.loadTrustedKeys()
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	for _, trustedKey := range trustedKeys {
+		if key.Equal(trustedKey) {
+			return SIGNATURE_VALID, nil
+		}
+	}
+	return SIGNATURE_UNTRUSTED, nil
+}
+
+func (repository Repository) VerifyCommit(commitId string) (SignatureStatus, error) {
+	lines, err := repository.Location.loadLines(".arciv/list/" + commitId)
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	sizes, err := repository.loadSideRecord(commitId, ".size")
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	info, err := repository.loadSideRecord(commitId, ".info")
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	return repository.verifySignature(".arciv/list/"+commitId, commitSignatureMessage(commitId, lines, sizes, info))
+}
+
+func (repository Repository) VerifyTimeline() (SignatureStatus, error) {
+	timeline, err := repository.LoadTimeline()
+	if err != nil {
+		return SIGNATURE_INVALID, err
+	}
+	return repository.verifySignature(".arciv/timeline", timelineSignatureMessage(timeline))
+}
+
+// loadTrustedKeys returns the local public key and public keys registered with 'arciv key trust' for the repository
+func (repository Repository) loadTrustedKeys() (keys []ed25519.PublicKey, err error) {
+	if signingKey != nil {
+		keys = append(keys, signingKey.Public().(ed25519.PublicKey))
+	}
+	trusted, err := loadTrustedKeyLines()
+	if err != nil {
+		return []ed25519.PublicKey{}, err
+	}
+	for _, line := range trusted {
+		elements := strings.Split(line, " ")
+		if elements[0] != repository.Name {
+			continue
+		}
+		key, err := str2publicKey(elements[1])
+		if err != nil {
+			return []ed25519.PublicKey{}, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func loadTrustedKeyLines() ([]string, error) {
+	lines, err := fileOp.loadLines(arcivDir() + "/trusted-keys")
+	if err != nil {
+		return []string{}, err
+	}
+	for _, line := range lines {
+		if len(strings.Split(line, " ")) != 2 {
+			return []string{}, errors.New(".arciv/trusted-keys is invalid syntax")
+		}
+	}
+	return lines, nil
+}
+
+func writeTrustedKeyLines(lines []string) error {
+	return fileOp.writeLines(arcivDir()+"/trusted-keys", lines)
+}
+
+// judgeSignature returns an error if history with the signature status must be refused.
+// If refusing is false, the status is only warned.
+func judgeSignature(target string, status SignatureStatus, refusing bool) error {
+	if status == SIGNATURE_VALID {
+		return nil
+	}
+	if status == SIGNATURE_UNSIGNED && signingKey == nil && !requireSignatureOption {
+		// signing is not used
+		return nil
+	}
+	var msg string
+	switch status {
+	case SIGNATURE_UNSIGNED:
+		msg = target + " is not signed"
+	case SIGNATURE_UNTRUSTED:
+		msg = target + " is signed with an untrusted key"
+	case SIGNATURE_INVALID:
+		msg = target + " has an invalid signature"
+	}
+	if refusing && (status != SIGNATURE_UNSIGNED || requireSignatureOption) {
+		return errors.New(msg)
+	}
+	message("warning: " + msg)
+	return nil
+}
+
+// verifyHistory checks signatures of the timeline and the commit, and the commit hash
+func verifyHistory(repo Repository, commit Commit, refusing bool) error {
+	status, err := repo.VerifyTimeline()
+	if err != nil {
+		return err
+	}
+	err = judgeSignature("The timeline of the repository "+repo.Name, status, refusing)
+	if err != nil {
+		return err
+	}
+	status, err = repo.VerifyCommit(commit.Id)
+	if err != nil {
+		return err
+	}
+	err = judgeSignature("The commit "+commit.Id, status, refusing)
+	if err != nil {
+		return err
+	}
+	err = commit.verifyHash()
+	if err != nil && refusing {
+		return err
+	}
+	if err != nil {
+		message("warning: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	repo := Repository{Name: "repo_name", Location: RepositoryLocationFile{Path: "root"}}
+	files := map[string][]string{}
+	fileOp = &FileOp{
+		rootDir: func() string { return "local_root" },
+		writeLines: func(path string, lines []string) error {
+			files[path] = lines
+			return nil
+		},
+		loadLines: func(path string) ([]string, error) {
+			return files[path], nil
+		},
+		isExist: func(path string) (bool, error) {
+			_, ok := files[path]
+			return ok, nil
+		},
+		removeFile: func(path string) error {
+			delete(files, path)
+			return nil
+		},
+	}
+	seed := make([]byte, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	timeline := []string{
+		"00000000-0000000000000000000000000000000000000000000000000000000000000000",
+		"11111111-1111111111111111111111111111111111111111111111111111111111111111",
+	}
+
+	// func strs2signingKey(lines []string) (ed25519.PrivateKey, error)
+	t.Run("strs2signingKey()", func(t *testing.T) {
+		got, err := strs2signingKey(signingKey2strs(key))
+		if err != nil {
+			t.Errorf("strs2signingKey() return an error \"%s\", want nil", err)
+		}
+		if !got.Equal(key) {
+			t.Errorf("strs2signingKey() return a different key")
+		}
+		_, err = strs2signingKey([]string{"#arciv-signing-key", "00"})
+		if err == nil {
+			t.Errorf("strs2signingKey() return nil, want an error")
+		}
+	})
+
+	// func (repository Repository) VerifyTimeline() (SignatureStatus, error)
+	t.Run("Repository.VerifyTimeline()", func(t *testing.T) {
+		signingKey = nil
+		err := repo.WriteTimeline(timeline)
+		if err != nil {
+			t.Errorf("Repository.WriteTimeline() return an error \"%s\", want nil", err)
+		}
+		got, err := repo.VerifyTimeline()
+		if err != nil || got != SIGNATURE_UNSIGNED {
+			t.Errorf("Repository.VerifyTimeline() = (%s, %v), want unsigned", got, err)
+		}
+
+		signingKey = key
+		err = repo.WriteTimeline(timeline)
+		if err != nil {
+			t.Errorf("Repository.WriteTimeline() return an error \"%s\", want nil", err)
+		}
+		got, err = repo.VerifyTimeline()
+		if err != nil || got != SIGNATURE_VALID {
+			t.Errorf("Repository.VerifyTimeline() = (%s, %v), want valid", got, err)
+		}
+
+		// tampered
+		files["root/.arciv/timeline"] = timeline[:1]
+		got, err = repo.VerifyTimeline()
+		if err != nil || got != SIGNATURE_INVALID {
+			t.Errorf("Repository.VerifyTimeline() = (%s, %v), want invalid", got, err)
+		}
+
+		// signed by an other key
+		signingKey = ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef"))
+		err = repo.WriteTimeline(timeline)
+		if err != nil {
+			t.Errorf("Repository.WriteTimeline() return an error \"%s\", want nil", err)
+		}
+		signingKey = key
+		got, err = repo.VerifyTimeline()
+		if err != nil || got != SIGNATURE_UNTRUSTED {
+			t.Errorf("Repository.VerifyTimeline() = (%s, %v), want untrusted", got, err)
+		}
+
+		// rewritten without a key after signed
+		signingKey = nil
+		err = repo.WriteTimeline(timeline[:1])
+		if err != nil {
+			t.Errorf("Repository.WriteTimeline() return an error \"%s\", want nil", err)
+		}
+		got, err = repo.VerifyTimeline()
+		if err != nil || got != SIGNATURE_UNSIGNED {
+			t.Errorf("Repository.VerifyTimeline() of the timeline rewritten without a key = (%s, %v), want unsigned", got, err)
+		}
+		signingKey = key
+	})
+
+	// func judgeSignature(target string, status SignatureStatus, refusing bool) error
+	t.Run("judgeSignature()", func(t *testing.T) {
+		requireSignatureOption = false
+		if judgeSignature("target", SIGNATURE_UNSIGNED, true) != nil {
+			t.Errorf("judgeSignature() refuse unsigned history without --require-signature")
+		}
+		if judgeSignature("target", SIGNATURE_INVALID, true) == nil {
+			t.Errorf("judgeSignature() does not refuse invalid history")
+		}
+		if judgeSignature("target", SIGNATURE_INVALID, false) != nil {
+			t.Errorf("judgeSignature() refuse invalid history without refusing")
+		}
+		requireSignatureOption = true
+		if judgeSignature("target", SIGNATURE_UNSIGNED, true) == nil {
+			t.Errorf("judgeSignature() does not refuse unsigned history with --require-signature")
+		}
+		requireSignatureOption = false
+	})
+	signingKey = nil
+}
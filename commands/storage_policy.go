@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// STORAGE_CLASS_DEFAULT is the storage class of blobs which no rule of the storage policy matches
+const STORAGE_CLASS_DEFAULT = "DEEP_ARCHIVE"
+
+// StoragePolicyRule chooses the storage class of a blob sent to a repository of AWS S3.
+// A rule matches a tag if all specified conditions match. Zero values are not specified.
+// A condition of the size or the modified time does not match a tag whose size or timestamp is unknown.
+type StoragePolicyRule struct {
+	Class   string
+	Paths   string // glob pattern of the path. "**" matches zero or more directories
+	Smaller int64  // the size is smaller than the bytes
+	Larger  int64  // the size is larger than or equal to the bytes
+	Older   int64  // the file is modified more than the days ago
+	Newer   int64  // the file is modified within the days
+}
+
+// Line returns a line of .arciv/storage-policy. The paths is always the last field, because it may include spaces.
+func (rule StoragePolicyRule) Line() string {
+	line := "class:" + rule.Class
+	if rule.Smaller > 0 {
+		line += " smaller:" + strconv.FormatInt(rule.Smaller, 10)
+	}
+	if rule.Larger > 0 {
+		line += " larger:" + strconv.FormatInt(rule.Larger, 10)
+	}
+	if rule.Older > 0 {
+		line += " older:" + strconv.FormatInt(rule.Older, 10)
+	}
+	if rule.Newer > 0 {
+		line += " newer:" + strconv.FormatInt(rule.Newer, 10)
+	}
+	if rule.Paths != "" {
+		line += " paths:" + rule.Paths
+	}
+	return line
+}
+
+func line2storagePolicyRule(line string) (StoragePolicyRule, error) {
+	var rule StoragePolicyRule
+	if idx := strings.Index(line, " paths:"); idx != -1 {
+		rule.Paths = line[idx+len(" paths:"):]
+		line = line[:idx]
+	}
+	for _, element := range strings.Split(line, " ") {
+		idx := strings.Index(element, ":")
+		if idx == -1 {
+			return StoragePolicyRule{}, errors.New("A field of the storage policy must be '<name>:<value>'")
+		}
+		name, value := element[:idx], element[idx+1:]
+		if name == "class" {
+			rule.Class = value
+			continue
+		}
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return StoragePolicyRule{}, err
+		}
+		switch name {
+		case "smaller":
+			rule.Smaller = number
+		case "larger":
+			rule.Larger = number
+		case "older":
+			rule.Older = number
+		case "newer":
+			rule.Newer = number
+		default:
+			return StoragePolicyRule{}, errors.New("Unknown field of the storage policy '" + name + "'")
+		}
+	}
+	if _, err := findStorageClassPrice(rule.Class); err != nil {
+		return StoragePolicyRule{}, err
+	}
+	return rule, nil
+}
+
+// match returns true if the tag matches the rule at the unix time now
+func (rule StoragePolicyRule) match(tag Tag, now int64) bool {
+	if rule.Paths != "" && !matchSegments(strings.Split(rule.Paths, "/"), strings.Split(tag.Path, "/")) {
+		return false
+	}
+	if (rule.Smaller > 0 || rule.Larger > 0) && !tag.UsedSize {
+		return false
+	}
+	if rule.Smaller > 0 && tag.Size >= rule.Smaller {
+		return false
+	}
+	if rule.Larger > 0 && tag.Size < rule.Larger {
+		return false
+	}
+	if (rule.Older > 0 || rule.Newer > 0) && !tag.UsedTimestamp {
+		return false
+	}
+	days := (now - tag.Timestamp) / (24 * 60 * 60)
+	if rule.Older > 0 && days < rule.Older {
+		return false
+	}
+	if rule.Newer > 0 && days >= rule.Newer {
+		return false
+	}
+	return true
+}
+
+// storageClassOf returns the storage class of the first rule which the tag matches
+func storageClassOf(rules []StoragePolicyRule, tag Tag, now int64) string {
+	for _, rule := range rules {
+		if rule.match(tag, now) {
+			return rule.Class
+		}
+	}
+	return STORAGE_CLASS_DEFAULT
+}
+
+// loadStoragePolicy loads rules in the order of evaluation. A repository without a policy has no .arciv/storage-policy
+func loadStoragePolicy(location RepositoryLocation) ([]StoragePolicyRule, error) {
+	exist, err := location.isExist(".arciv/storage-policy")
+	if err != nil || !exist {
+		return []StoragePolicyRule{}, err
+	}
+	lines, err := location.loadLines(".arciv/storage-policy")
+	if err != nil {
+		return []StoragePolicyRule{}, err
+	}
+	if len(lines) == 0 || lines[0] != "#arciv-storage-policy" {
+		return []StoragePolicyRule{}, errors.New("The first line of .arciv/storage-policy must be '#arciv-storage-policy'")
+	}
+	var rules []StoragePolicyRule
+	for _, line := range lines[1:] {
+		rule, err := line2storagePolicyRule(line)
+		if err != nil {
+			return []StoragePolicyRule{}, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func writeStoragePolicy(location RepositoryLocation, rules []StoragePolicyRule) error {
+	lines := []string{"#arciv-storage-policy"}
+	for _, rule := range rules {
+		lines = append(lines, rule.Line())
+	}
+	return location.writeLines(".arciv/storage-policy", lines)
+}
+
+// str2size parses a size in bytes with an optional suffix K, M, G or T (powers of 1024)
+func str2size(str string) (int64, error) {
+	units := map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+	unit := int64(1)
+	if len(str) > 0 {
+		if u, ok := units[strings.ToUpper(str[len(str)-1:])]; ok {
+			unit = u
+			str = str[:len(str)-1]
+		}
+	}
+	size, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || size < 0 {
+		return 0, errors.New("The size '" + str + "' is invalid. It must be a number with an optional suffix K, M, G or T")
+	}
+	return size * unit, nil
+}
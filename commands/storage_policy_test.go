@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoragePolicy(t *testing.T) {
+	const day = 24 * 60 * 60
+	now := int64(1000 * day)
+	rules := []StoragePolicyRule{
+		{Class: "STANDARD_IA", Smaller: 1024 * 1024},
+		{Class: "GLACIER_IR", Paths: "projects/2024/**", Newer: 365},
+		{Class: "DEEP_ARCHIVE", Paths: "raw footage/**", Larger: 1024 * 1024 * 1024, Older: 30},
+	}
+
+	// func line2storagePolicyRule(line string) (StoragePolicyRule, error)
+	t.Run("line2storagePolicyRule()", func(t *testing.T) {
+		for _, rule := range rules {
+			got, err := line2storagePolicyRule(rule.Line())
+			if err != nil || got != rule {
+				t.Errorf("line2storagePolicyRule(%q) = (%+v, %v), want %+v", rule.Line(), got, err, rule)
+			}
+		}
+		for _, line := range []string{"class:UNKNOWN", "class:STANDARD size:10", "class:STANDARD smaller:1M"} {
+			if _, err := line2storagePolicyRule(line); err == nil {
+				t.Errorf("line2storagePolicyRule(%q) return nil, want error", line)
+			}
+		}
+	})
+
+	// func storageClassOf(rules []StoragePolicyRule, tag Tag, now int64) string
+	t.Run("storageClassOf()", func(t *testing.T) {
+		cases := []struct {
+			tag  Tag
+			want string
+		}{
+			{Tag{Path: "projects/2024/a.xmp", Size: 1024, Timestamp: now - 400*day, UsedSize: true, UsedTimestamp: true}, "STANDARD_IA"},
+			{Tag{Path: "projects/2024/a.mov", Size: 1024 * 1024 * 1024, Timestamp: now - 10*day, UsedSize: true, UsedTimestamp: true}, "GLACIER_IR"},
+			{Tag{Path: "projects/2024/b.mov", Size: 1024 * 1024 * 1024, Timestamp: now - 400*day, UsedSize: true, UsedTimestamp: true}, STORAGE_CLASS_DEFAULT},
+			{Tag{Path: "raw footage/c.mov", Size: 2 * 1024 * 1024 * 1024, Timestamp: now - 10*day, UsedSize: true, UsedTimestamp: true}, STORAGE_CLASS_DEFAULT},
+			{Tag{Path: "raw footage/c.mov", Size: 2 * 1024 * 1024 * 1024, Timestamp: now - 40*day, UsedSize: true, UsedTimestamp: true}, "DEEP_ARCHIVE"},
+			// conditions of an unknown size or timestamp do not match (ex. a tag loaded from an old commit)
+			{Tag{Path: "projects/2024/a.xmp"}, STORAGE_CLASS_DEFAULT},
+			{Tag{Path: "projects/2024/a.mov", Size: 1024 * 1024 * 1024, UsedSize: true}, STORAGE_CLASS_DEFAULT},
+		}
+		for _, c := range cases {
+			if got := storageClassOf(rules, c.tag, now); got != c.want {
+				t.Errorf("storageClassOf(%s) = %s, want %s", c.tag.Path, got, c.want)
+			}
+		}
+	})
+
+	// func str2size(str string) (int64, error)
+	t.Run("str2size()", func(t *testing.T) {
+		cases := map[string]int64{"100": 100, "512K": 512 * 1024, "1m": 1024 * 1024, "2G": 2 * 1024 * 1024 * 1024}
+		for str, want := range cases {
+			if got, err := str2size(str); err != nil || got != want {
+				t.Errorf("str2size(%s) = (%d, %v), want %d", str, got, err, want)
+			}
+		}
+		for _, str := range []string{"", "M", "-1K", "1.5G"} {
+			if _, err := str2size(str); err == nil {
+				t.Errorf("str2size(%q) return nil, want error", str)
+			}
+		}
+	})
+
+	// func (r RepositoryLocationS3) SendLocalBlobs(tags []Tag) (err error)
+	t.Run("RepositoryLocationS3.SendLocalBlobs()", func(t *testing.T) {
+		fileOp = &FileOp{
+			rootDir: func() string {
+				return "/root"
+			},
+		}
+		var gotClasses []string
+		s3Op = &S3Op{
+			isExist: func(region string, bucket string, path string) (bool, error) {
+				return path == ".arciv/storage-policy", nil
+			},
+			loadLines: func(region string, bucket string, path string) ([]string, error) {
+				return []string{"#arciv-storage-policy", "class:STANDARD_IA smaller:1024"}, nil
+			},
+			sendBlobs: func(region string, bucket string, paths, names, storageClasses []string) error {
+				if strings.Join(paths, ",") != "/root/small.txt,/root/large.txt" {
+					t.Errorf("s3Op.sendBlobs() gets invalid paths, %v", paths)
+				}
+				gotClasses = storageClasses
+				return nil
+			},
+		}
+		tags := []Tag{
+			{Path: "small.txt", Size: 10, Timestamp: time.Now().Unix(), UsedSize: true, UsedTimestamp: true},
+			{Path: "large.txt", Size: 4096, Timestamp: time.Now().Unix(), UsedSize: true, UsedTimestamp: true},
+		}
+		err := RepositoryLocationS3{RegionName: "region", BucketName: "bucket"}.SendLocalBlobs(tags)
+		if err != nil || strings.Join(gotClasses, ",") != "STANDARD_IA,"+STORAGE_CLASS_DEFAULT {
+			t.Errorf("SendLocalBlobs() sends with classes %v and returns %v, want [STANDARD_IA %s] and nil", gotClasses, err, STORAGE_CLASS_DEFAULT)
+		}
+	})
+}
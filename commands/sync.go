@@ -0,0 +1,316 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// mergeTimelines returns the union of timelines ordered topologically by parents, so a commit follows its parents.
+// Timestamps of commit ids only break ties, because a computer whose clock runs behind may create a child older than its parent.
+// Commits of the same timestamp keep the order of the destination, followed by the source.
+// If keepingLatest is true, the latest commit of the destination stays the latest,
+// because the latest commit of the self repository describes files in it.
+func mergeTimelines(destination, source []string, parentsOf parentsFunc, keepingLatest bool) ([]string, error) {
+	union := append([]string{}, destination...)
+	for _, commitId := range source {
+		if !isIncluded(union, commitId) {
+			union = append(union, commitId)
+		}
+	}
+	parents := make(map[string][]string)
+	for _, commitId := range union {
+		ids, err := parentsOf(commitId)
+		if err != nil {
+			return []string{}, err
+		}
+		for _, parent := range ids {
+			if isIncluded(union, parent) {
+				parents[commitId] = append(parents[commitId], parent)
+			}
+		}
+	}
+	var merged []string
+	placed := make(map[string]bool)
+	for len(merged) < len(union) {
+		next := ""
+		for _, commitId := range union {
+			if placed[commitId] || !allPlaced(parents[commitId], placed) {
+				continue
+			}
+			if next == "" || commitId[:8] < next[:8] {
+				next = commitId
+			}
+		}
+		if next == "" {
+			// parents make a cycle. the oldest commit is placed to break it
+			for _, commitId := range union {
+				if !placed[commitId] && (next == "" || commitId[:8] < next[:8]) {
+					next = commitId
+				}
+			}
+		}
+		placed[next] = true
+		merged = append(merged, next)
+	}
+	if keepingLatest && len(destination) > 0 {
+		latest := destination[len(destination)-1]
+		idx := indexOf(merged, latest)
+		merged = append(append(merged[:idx], merged[idx+1:]...), latest)
+	}
+	return merged, nil
+}
+
+func allPlaced(commitIds []string, placed map[string]bool) bool {
+	for _, commitId := range commitIds {
+		if !placed[commitId] {
+			return false
+		}
+	}
+	return true
+}
+
+// missingCommits returns commit ids of the source which the destination does not have
+func missingCommits(destination, source []string) (missing []string) {
+	for _, commitId := range source {
+		if !isIncluded(destination, commitId) {
+			missing = append(missing, commitId)
+		}
+	}
+	return missing
+}
+
+// copyBlobs copies blobs of tags from a repository to another repository.
+// Blobs between repositories other than the self repository are streamed without a local copy.
+func copyBlobs(from, to Repository, tags []Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	if to.Name != "self" && from.Name != "self" {
+		return replicateBlobs(from, to, tags)
+	}
+	if size, known := sizeOfTags(tags, true); known {
+		message("copying " + strconv.Itoa(len(tags)) + " files, " + size2string(size))
+	}
+	if to.Name == "self" {
+		return from.ReceiveRemoteBlobs(tags)
+	}
+	localBlobs, err := SelfRepo().FetchBlobHashes()
+	if err != nil {
+		return err
+	}
+	current, err := createCommitStructure()
+	if err != nil {
+		return err
+	}
+	var sending []Tag
+	for _, tag := range tags {
+		tag, err = localBlobSource(tag, current.Tags, localBlobs)
+		if err != nil {
+			return err
+		}
+		sending = append(sending, tag)
+	}
+	return to.SendLocalBlobs(sending)
+}
+
+// COMMIT_FILE_SUFFIXES are suffixes of files recorded with .arciv/list/<commit-id>
+var COMMIT_FILE_SUFFIXES = []string{"", ".sig", ".xattr", ".size", ".info"}
+
+// copyCommitFiles copies the tag list file of the commit and files recorded with it as they are, so the signature stays valid.
+// If the commit is an extension, the base commits which the destination does not have are also copied.
+func copyCommitFiles(from, to Repository, commitId string) error {
+	// the depth of the extensions is limited when the commit is loaded
+	for {
+		var lines []string
+		for _, suffix := range COMMIT_FILE_SUFFIXES {
+			path := ".arciv/list/" + commitId + suffix
+			exist, err := from.Location.isExist(path)
+			if err != nil {
+				return err
+			}
+			if !exist {
+				if suffix == "" {
+					return errors.New("The commit " + commitId + " does not exist in the repository " + from.Name)
+				}
+				continue
+			}
+			loaded, err := from.Location.loadLines(path)
+			if err != nil {
+				return err
+			}
+			err = to.Location.writeLines(path, loaded)
+			if err != nil {
+				return err
+			}
+			if suffix == "" {
+				lines = loaded
+			}
+		}
+		if len(lines) == 0 || !strings.HasPrefix(lines[0], "#") {
+			return nil
+		}
+		base, err := parseListFirstLine(lines[0])
+		if err != nil || base == "" {
+			return err
+		}
+		commitId = base
+		exist, err := to.Location.isExist(".arciv/list/" + commitId)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return nil
+		}
+	}
+}
+
+// findRepoPair finds repositories to transfer commits from and to
+func findRepoPair(fromName, toName string) (from Repository, to Repository, err error) {
+	if fromName == toName {
+		return Repository{}, Repository{}, errors.New("The repositories to copy commits from and to are the same")
+	}
+	from, err = findRepo(fromName)
+	if err != nil {
+		return Repository{}, Repository{}, err
+	}
+	to, err = findRepo(toName)
+	if err != nil {
+		return Repository{}, Repository{}, err
+	}
+	return from, to, nil
+}
+
+// blobTransfer transfers blobs of tags from a repository to another repository
+type blobTransfer func(from, to Repository, tags []Tag) error
+
+// transferCommits copies commits in the range (all commits if it is empty) which the repository does not have,
+// and merges the timelines. Blobs of the commits which the repository does not have are transferred if transfer is not nil.
+func transferCommits(from, to Repository, commitRange string, transfer blobTransfer) error {
+	status, err := from.VerifyTimeline()
+	if err != nil {
+		return err
+	}
+	err = judgeSignature("The timeline of the repository "+from.Name, status, true)
+	if err != nil {
+		return err
+	}
+
+	fromTimeline, err := from.LoadTimeline()
+	if err != nil {
+		return err
+	}
+	if commitRange != "" {
+		labels, err := from.LoadLabels()
+		if err != nil {
+			return err
+		}
+		fromTimeline, err = findCommitRange(commitRange, fromTimeline, labels)
+		if err != nil {
+			return err
+		}
+	}
+	toTimeline, err := to.LoadTimeline()
+	if err != nil {
+		return err
+	}
+	err = reportSyncRelation(from, to, fromTimeline, toTimeline)
+	if err != nil {
+		return err
+	}
+	missing := missingCommits(toTimeline, fromTimeline)
+	if dryRunningOption {
+		message("Show commits to copy if you excute the command.")
+		for _, commitId := range missing {
+			messageStdin("copy: " + commitId)
+		}
+		return nil
+	}
+
+	var blobs []string
+	if transfer != nil {
+		blobs, err = to.FetchBlobHashes()
+		if err != nil {
+			return err
+		}
+	}
+	// with blobs, commits which the repository already has are checked, because transferring may have been interrupted
+	checking := missing
+	if transfer != nil {
+		checking = fromTimeline
+	}
+	for _, commitId := range checking {
+		commit, err := from.LoadCommit(commitId)
+		if err != nil {
+			return err
+		}
+		if transfer != nil {
+			var transferring []Tag
+			for _, tag := range blobTags(commit.Tags) {
+				if !isIncluded(blobs, tag.Hash.String()) {
+					transferring = append(transferring, tag)
+					blobs = append(blobs, tag.Hash.String())
+				}
+			}
+			err = transfer(from, to, transferring)
+			if err != nil {
+				return err
+			}
+		}
+		if !isIncluded(missing, commitId) {
+			continue
+		}
+		err = verifyHistory(from, commit, true)
+		if err != nil {
+			return err
+		}
+		err = copyCommitFiles(from, to, commitId)
+		if err != nil {
+			return err
+		}
+		message("copied commit '" + commitId + "'")
+	}
+	if len(missing) == 0 {
+		message("The repository " + to.Name + " already has all commits of the repository " + from.Name)
+		return copyLabels(from, to)
+	}
+	parentsOf, err := commitParents(from, to)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeTimelines(toTimeline, fromTimeline, parentsOf, to.Name == "self")
+	if err != nil {
+		return err
+	}
+	err = to.WriteTimeline(merged)
+	if err != nil {
+		return err
+	}
+	message("copied " + strconv.Itoa(len(missing)) + " commits from the repository " + from.Name + " to the repository " + to.Name)
+	return copyLabels(from, to)
+}
+
+// reportSyncRelation reports the relation of the latest commits of the repositories
+func reportSyncRelation(from, to Repository, fromTimeline, toTimeline []string) error {
+	if len(fromTimeline) == 0 || len(toTimeline) == 0 {
+		return nil
+	}
+	fromLatest, toLatest := fromTimeline[len(fromTimeline)-1], toTimeline[len(toTimeline)-1]
+	parentsOf, err := commitParents(from, to)
+	if err != nil {
+		return err
+	}
+	relation, err := commitRelation(toLatest, fromLatest, parentsOf)
+	if err != nil {
+		return err
+	}
+	switch relation {
+	case RELATION_FAST_FORWARD:
+		message("The latest commit '" + fromLatest + "' of the repository " + from.Name + " follows the latest commit of the repository " + to.Name + " (fast-forward)")
+	case RELATION_BEHIND:
+		message("The latest commit '" + fromLatest + "' of the repository " + from.Name + " is an ancestor of the latest commit of the repository " + to.Name)
+	case RELATION_DIVERGED:
+		message("warning: the timelines have diverged. the latest commit of the repository " + from.Name + " is '" + fromLatest + "', and the latest commit of the repository " + to.Name + " is '" + toLatest + "'")
+	}
+	return nil
+}
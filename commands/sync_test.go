@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	// func mergeTimelines(destination, source []string, parentsOf parentsFunc, keepingLatest bool) ([]string, error)
+	t.Run("mergeTimelines()", func(t *testing.T) {
+		destination := []string{
+			"00000000-0000000000000000000000000000000000000000000000000000000000000000",
+			"22222222-2222222222222222222222222222222222222222222222222222222222222222",
+		}
+		source := []string{
+			"00000000-0000000000000000000000000000000000000000000000000000000000000000",
+			"11111111-1111111111111111111111111111111111111111111111111111111111111111",
+			"33333333-3333333333333333333333333333333333333333333333333333333333333333",
+		}
+		noParents := func(commitId string) ([]string, error) {
+			return []string{}, nil
+		}
+		got, err := mergeTimelines(destination, source, noParents, false)
+		want := []string{source[0], source[1], destination[1], source[2]}
+		if err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("mergeTimelines() = (%s, %v), want %s", got, err, want)
+		}
+		got, err = mergeTimelines(destination, source, noParents, true)
+		want = []string{source[0], source[1], source[2], destination[1]}
+		if err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("mergeTimelines() keeping the latest = (%s, %v), want %s", got, err, want)
+		}
+		if len(destination) != 2 || destination[1][:1] != "2" {
+			t.Errorf("mergeTimelines() changes the destination %s", destination)
+		}
+
+		// a child created by a computer whose clock runs behind follows its parent
+		// 0 - 2 (destination)
+		//  \- 3 - 1 (source. 1 is older than 3)
+		parents := map[string][]string{
+			source[1]:      {source[2]},
+			source[2]:      {source[0]},
+			destination[1]: {source[0]},
+		}
+		parentsOf := func(commitId string) ([]string, error) {
+			return parents[commitId], nil
+		}
+		got, err = mergeTimelines(destination, source, parentsOf, false)
+		want = []string{source[0], destination[1], source[2], source[1]}
+		if err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("mergeTimelines() with parents = (%s, %v), want %s", got, err, want)
+		}
+
+		// func missingCommits(destination, source []string) (missing []string)
+		missing := missingCommits(destination, source)
+		if strings.Join(missing, ",") != source[1]+","+source[2] {
+			t.Errorf("missingCommits() = %s, want %s", missing, source[1:])
+		}
+	})
+
+	// func copyCommitFiles(from, to Repository, commitId string) error
+	t.Run("copyCommitFiles()", func(t *testing.T) {
+		files := make(map[string][]string)
+		fileOp = &FileOp{
+			writeLines: func(path string, lines []string) error {
+				files[path] = lines
+				return nil
+			},
+			loadLines: func(path string) ([]string, error) {
+				return files[path], nil
+			},
+			isExist: func(path string) (bool, error) {
+				_, ok := files[path]
+				return ok, nil
+			},
+			rootDir: func() string {
+				return "self"
+			},
+		}
+		signingKey = ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef"))
+		defer func() { signingKey = nil }()
+		from := Repository{Name: "from", Location: RepositoryLocationFile{Path: "from"}}
+		to := Repository{Name: "to", Location: RepositoryLocationFile{Path: "to"}}
+		base := Commit{
+			Id: "bbbbbbbb-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			Tags: []Tag{
+				Tag{Path: "0000/0000", Hash: hashing("0000000000000000000000000000000000000000000000000000000000000000"), Size: 1, UsedSize: true},
+			},
+		}
+		commit := Commit{
+			Id:      "aaaaaaaa-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			Message: "a message",
+			Tags: []Tag{
+				Tag{Path: "1111/1111", Hash: hashing("1111111111111111111111111111111111111111111111111111111111111111"), Size: 2, UsedSize: true},
+			},
+		}
+		if err := from.WriteTags(base, nil); err != nil {
+			t.Fatalf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+		if err := from.WriteTags(commit, &base); err != nil {
+			t.Fatalf("Repository.WriteTags() return error \"%s\", want nil", err)
+		}
+
+		err := copyCommitFiles(from, to, commit.Id)
+		if err != nil {
+			t.Errorf("copyCommitFiles() return error \"%s\", want nil", err)
+		}
+		for _, commitId := range []string{base.Id, commit.Id} {
+			for _, suffix := range []string{"", ".sig", ".size"} {
+				path := "/.arciv/list/" + commitId + suffix
+				if strings.Join(files["to"+path], "\n") != strings.Join(files["from"+path], "\n") {
+					t.Errorf("copyCommitFiles() copies %s as %s, want %s", path, files["to"+path], files["from"+path])
+				}
+			}
+		}
+		if strings.Join(files["to/.arciv/list/"+commit.Id+".info"], "\n") != strings.Join(files["from/.arciv/list/"+commit.Id+".info"], "\n") {
+			t.Errorf("copyCommitFiles() does not copy .info")
+		}
+		status, err := to.VerifyCommit(commit.Id)
+		if err != nil || status != SIGNATURE_VALID {
+			t.Errorf("Repository.VerifyCommit() of a copied commit = (%v, %v), want SIGNATURE_VALID", status, err)
+		}
+		loaded, err := to.LoadCommit(commit.Id)
+		if err != nil || loaded.Message != "a message" || len(loaded.Tags) != 1 || loaded.Depth != 1 {
+			t.Errorf("Repository.LoadCommit() of a copied commit = (%+v, %v)", loaded, err)
+		}
+
+		// the base which the destination has is not copied again
+		files["to/.arciv/list/"+base.Id] = []string{"#arciv-commit-atom"}
+		err = copyCommitFiles(from, to, commit.Id)
+		if err != nil || len(files["to/.arciv/list/"+base.Id]) != 1 {
+			t.Errorf("copyCommitFiles() = %v, and overwrites the base which the destination has", err)
+		}
+
+		err = copyCommitFiles(from, to, "cccccccc-cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc")
+		if err == nil {
+			t.Errorf("copyCommitFiles() of a commit which does not exist return nil, want an error")
+		}
+	})
+}
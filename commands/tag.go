@@ -6,36 +6,298 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type Tag struct {
 	Path          string
 	Hash          Hash
 	Timestamp     int64
+	Size          int64
+	Metadata      FileMetadata
+	Type          string  // TAG_TYPE_*. empty means TAG_TYPE_FILE
+	Target        string  // the target of a symbolic link, or the path of the first file of a hardlink group
+	Xattrs        []Xattr // sorted by the name. recorded in .arciv/list/<commit-id>.xattr
+	FsPath        string  // the path on the local disk if it differs from the normalized Path. not recorded
 	UsedTimestamp bool
 	UsedHash      bool
+	UsedSize      bool
+	UsedMetadata  bool
+	UsedXattrs    bool
+}
+
+const (
+	TAG_TYPE_FILE     = "file"
+	TAG_TYPE_SYMLINK  = "symlink"
+	TAG_TYPE_HARDLINK = "hardlink" // a file sharing the inode with the file of the path Target
+	TAG_TYPE_DIR      = "dir"      // an empty directory
+	TAG_TYPE_FIFO     = "fifo"
+	TAG_TYPE_SOCKET   = "socket"   // recorded, but not created on restore
+	TAG_TYPE_CHARDEV  = "chardev"  // the target is the device number
+	TAG_TYPE_BLOCKDEV = "blockdev" // the target is the device number
+	TAG_TYPE_NESTED   = "nested"   // a nested repository. the target is the latest commit id of it
+)
+
+var tagTypes = []string{TAG_TYPE_FILE, TAG_TYPE_SYMLINK, TAG_TYPE_HARDLINK, TAG_TYPE_DIR, TAG_TYPE_FIFO, TAG_TYPE_SOCKET, TAG_TYPE_CHARDEV, TAG_TYPE_BLOCKDEV, TAG_TYPE_NESTED}
+
+func (tag Tag) entryType() string {
+	if tag.Type == "" {
+		return TAG_TYPE_FILE
+	}
+	return tag.Type
+}
+
+// hasBlob returns true if the content of the tag is stored as a blob.
+// A hardlink shares the blob of the first file of the group.
+func (tag Tag) hasBlob() bool {
+	return tag.entryType() == TAG_TYPE_FILE
+}
+
+func blobTags(tags []Tag) (filtered []Tag) {
+	for _, tag := range tags {
+		if tag.hasBlob() {
+			filtered = append(filtered, tag)
+		}
+	}
+	return filtered
 }
 
 func (tag Tag) String() string {
 	return tag.Hash.String() + " " + tag.Path
 }
 
+// Fields of a line of a tag list file. The path is always the last field.
+// A tag list file without '#fields:' header is written with TAG_FIELDS_LEGACY.
+var TAG_FIELDS_LEGACY = []string{"hash", "path"}
+
+// tagFields returns fields which all tags have.
+// Sizes are not included, because they are recorded in .arciv/list/<commit-id>.size to keep lines readable by older versions.
+func tagFields(tags []Tag) []string {
+	fields := []string{"hash"}
+	usedMetadata := len(tags) > 0
+	usedType := false
+	for _, tag := range tags {
+		usedMetadata = usedMetadata && tag.UsedMetadata
+		usedType = usedType || tag.entryType() != TAG_TYPE_FILE
+	}
+	if usedMetadata {
+		fields = append(fields, TAG_FIELDS_METADATA...)
+	}
+	if usedType {
+		fields = append(fields, "type", "target")
+	}
+	return append(fields, "path")
+}
+
+func (tag Tag) Line(fields []string) string {
+	var elements []string
+	for _, field := range fields {
+		switch field {
+		case "hash":
+			elements = append(elements, tag.Hash.String())
+		case "size":
+			elements = append(elements, strconv.FormatInt(tag.Size, 10))
+		case "mode":
+			elements = append(elements, mode2string(tag.Metadata.Mode))
+		case "uid":
+			elements = append(elements, strconv.Itoa(tag.Metadata.Uid))
+		case "gid":
+			elements = append(elements, strconv.Itoa(tag.Metadata.Gid))
+		case "user":
+			elements = append(elements, name2string(tag.Metadata.User))
+		case "group":
+			elements = append(elements, name2string(tag.Metadata.Group))
+		case "mtime":
+			elements = append(elements, strconv.FormatInt(tag.Metadata.Mtime, 10))
+		case "type":
+			elements = append(elements, tag.entryType())
+		case "target":
+			elements = append(elements, escapeField(tag.Target))
+		case "path":
+			elements = append(elements, tag.Path)
+		}
+	}
+	return strings.Join(elements, " ")
+}
+
+func line2Tag(line string, fields []string) (Tag, error) {
+	tag := Tag{UsedHash: true}
+	for i, field := range fields {
+		var element string
+		if i == len(fields)-1 {
+			// the last field may include spaces
+			element = line
+		} else {
+			idx := strings.Index(line, " ")
+			if idx == -1 {
+				return Tag{}, errors.New("Tag's line must have " + strconv.Itoa(len(fields)) + " fields separated by a space")
+			}
+			element, line = line[:idx], line[idx+1:]
+		}
+		switch field {
+		case "hash":
+			hash, err := hex2hash(element)
+			if err != nil {
+				return Tag{}, err
+			}
+			tag.Hash = hash
+		case "size":
+			size, err := strconv.ParseInt(element, 10, 64)
+			if err != nil {
+				return Tag{}, err
+			}
+			tag.Size = size
+			tag.UsedSize = true
+		case "mode":
+			mode, err := str2mode(element)
+			if err != nil {
+				return Tag{}, err
+			}
+			tag.Metadata.Mode = mode
+			tag.UsedMetadata = true
+		case "uid", "gid":
+			id, err := strconv.Atoi(element)
+			if err != nil {
+				return Tag{}, err
+			}
+			if field == "uid" {
+				tag.Metadata.Uid = id
+			} else {
+				tag.Metadata.Gid = id
+			}
+		case "user":
+			tag.Metadata.User = str2name(element)
+		case "group":
+			tag.Metadata.Group = str2name(element)
+		case "mtime":
+			mtime, err := strconv.ParseInt(element, 10, 64)
+			if err != nil {
+				return Tag{}, err
+			}
+			tag.Metadata.Mtime = mtime
+		case "type":
+			if !isIncluded(tagTypes, element) {
+				return Tag{}, errors.New("Unknown type of a tag '" + element + "'")
+			}
+			tag.Type = element
+		case "target":
+			target, err := unescapeField(element)
+			if err != nil {
+				return Tag{}, err
+			}
+			tag.Target = target
+		case "path":
+			if element == "" {
+				return Tag{}, errors.New("Tag's path is empty")
+			}
+			tag.Path = element
+		default:
+			return Tag{}, errors.New("Unknown field of a tag list '" + field + "'")
+		}
+	}
+	return tag, nil
+}
+
+// hashLine is a line to hash a commit.
+// Metadata, the type and the target are hashed, so a commit records changes of them.
+func (tag Tag) hashLine() string {
+	fields := []string{"hash"}
+	if tag.UsedMetadata {
+		fields = append(fields, TAG_FIELDS_METADATA...)
+	}
+	if tag.entryType() != TAG_TYPE_FILE {
+		fields = append(fields, "type", "target")
+	}
+	if len(fields) == 1 {
+		return tag.String()
+	}
+	return tag.Line(append(fields, "path"))
+}
+
+// PATH_ENCODING_PERCENT is the encoding of paths in a tag list file with the header '#path-encoding:percent'.
+// '%', control characters (including a newline) and bytes of invalid UTF-8 are written as "%XX".
+// A tag list file without the header has raw paths, so paths which need encoding can not be written.
+const PATH_ENCODING_PERCENT = "percent"
+
+func percentEncode(str string, escapingSpace bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(str); {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		c := str[i]
+		if c == '%' || c < ' ' || c == 0x7f || c == ' ' && escapingSpace || r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&builder, "%%%02X", c)
+			i++
+			continue
+		}
+		builder.WriteString(str[i : i+size])
+		i += size
+	}
+	return builder.String()
+}
+
+func needsPathEncoding(path string) bool {
+	return percentEncode(path, false) != path
+}
+
+// pathEncodingOf returns PATH_ENCODING_PERCENT if any path of tags needs encoding
+func pathEncodingOf(tags []Tag) string {
+	for _, tag := range tags {
+		if needsPathEncoding(tag.Path) {
+			return PATH_ENCODING_PERCENT
+		}
+	}
+	return ""
+}
+
+func encodePath(path string) string {
+	return percentEncode(path, false)
+}
+
+func decodePath(str string) (string, error) {
+	return percentDecode(str)
+}
+
+// escapeField escapes a field which may include spaces except the path (ex. the target of a symbolic link).
+// Spaces are also written as "%XX", and an empty string is written as "-".
+func escapeField(str string) string {
+	if str == "" {
+		return "-"
+	}
+	if str == "-" {
+		return "%2D"
+	}
+	return percentEncode(str, true)
+}
+
+func unescapeField(str string) (string, error) {
+	if str == "-" {
+		return "", nil
+	}
+	return percentDecode(str)
+}
+
+func percentDecode(str string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] != '%' {
+			builder.WriteByte(str[i])
+			continue
+		}
+		if i+2 >= len(str) {
+			return "", errors.New("A percent-encoded string '" + str + "' is invalid")
+		}
+		c, err := strconv.ParseUint(str[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", errors.New("A percent-encoded string '" + str + "' is invalid")
+		}
+		builder.WriteByte(byte(c))
+		i += 2
+	}
+	return builder.String(), nil
+}
+
 func str2Tag(line string) (Tag, error) {
-	// 64...Hash, 1...space
-	if len(line) <= 64+1 {
-		return Tag{}, errors.New("The length of Tag's line must be more than 65")
-	}
-	hash, err := hex2hash(line[:64])
-	if err != nil {
-		return Tag{}, err
-	}
-	return Tag{
-		Path:          line[65:],
-		Hash:          hash,
-		Timestamp:     0,
-		UsedTimestamp: false,
-		UsedHash:      true,
-	}, nil
+	return line2Tag(line, TAG_FIELDS_LEGACY)
 }
 
 func str2timestamp(str string) (int64, error) {
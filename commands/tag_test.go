@@ -2,6 +2,8 @@ package commands
 
 import (
 	"bytes"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -53,6 +55,95 @@ func TestTag(t *testing.T) {
 		}
 	})
 
+	// func (tag Tag) Line(fields []string) string
+	// func line2Tag(line string, fields []string) (Tag, error)
+	t.Run("line2Tag()", func(t *testing.T) {
+		fields := []string{"hash", "size", "path"}
+		line := "0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7 1024 dir/file name.jpg"
+		got, err := line2Tag(line, fields)
+		if err != nil {
+			t.Errorf("line2Tag(%s) return an error %s", line, err)
+		}
+		if got.Path != "dir/file name.jpg" || got.Size != 1024 || !got.UsedSize || bytes.Compare(got.Hash, tagHash) != 0 {
+			t.Errorf("line2Tag(%s) = {Path: %s, Size: %d ...}", line, got.Path, got.Size)
+		}
+		if got.Line(fields) != line {
+			t.Errorf("Tag.Line() = %s, want %s", got.Line(fields), line)
+		}
+		if got.Line(TAG_FIELDS_LEGACY) != "0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7 dir/file name.jpg" {
+			t.Errorf("Tag.Line() = %s", got.Line(TAG_FIELDS_LEGACY))
+		}
+		_, err = line2Tag("0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7 dir/file", fields)
+		if err == nil {
+			t.Errorf("line2Tag() return nil, want an error")
+		}
+
+		fields = append(append([]string{"hash", "size"}, TAG_FIELDS_METADATA...), "path")
+		line = "0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7 1024 4755 1000 100 - users 1614859405123456789 bin/run"
+		got, err = line2Tag(line, fields)
+		if err != nil {
+			t.Errorf("line2Tag(%s) return an error %s", line, err)
+		}
+		want := FileMetadata{Mode: 0755 | os.ModeSetuid, Uid: 1000, Gid: 100, User: "", Group: "users", Mtime: 1614859405123456789}
+		if !got.UsedMetadata || got.Metadata != want {
+			t.Errorf("line2Tag(%s) return metadata %v, want %v", line, got.Metadata, want)
+		}
+		if got.Line(fields) != line {
+			t.Errorf("Tag.Line() = %s, want %s", got.Line(fields), line)
+		}
+		_, err = line2Tag("0223497a0b8b033aa58a3a521b8629869386cf7ab0e2f101963d328aa62193f7 1024 98 1000 100 - users 0 bin/run", fields)
+		if err == nil {
+			t.Errorf("line2Tag() return nil with an invalid mode, want an error")
+		}
+	})
+
+	// func escapeField(str string) string
+	// func unescapeField(str string) (string, error)
+	t.Run("escapeField()", func(t *testing.T) {
+		for _, str := range []string{"", "-", "../a b/100%", "tab\tnewline\n"} {
+			escaped := escapeField(str)
+			if strings.ContainsAny(escaped, " \t\n") || escaped == "" {
+				t.Errorf("escapeField(%q) = %q, which includes a separator", str, escaped)
+			}
+			got, err := unescapeField(escaped)
+			if err != nil || got != str {
+				t.Errorf("unescapeField(%q) = (%q, %v), want %q", escaped, got, err, str)
+			}
+		}
+		_, err := unescapeField("abc%2")
+		if err == nil {
+			t.Errorf("unescapeField() return nil, want an error")
+		}
+	})
+
+	// func encodePath(path string) string
+	// func decodePath(str string) (string, error)
+	t.Run("encodePath()", func(t *testing.T) {
+		cases := []struct {
+			path    string
+			encoded string
+		}{
+			{"a b/c.txt", "a b/c.txt"},
+			{"100%", "100%25"},
+			{"line\nfeed\r", "line%0Afeed%0D"},
+			{"\xff\xfe", "%FF%FE"},
+			{"日本語", "日本語"},
+		}
+		for _, c := range cases {
+			got := encodePath(c.path)
+			if got != c.encoded {
+				t.Errorf("encodePath(%q) = %q, want %q", c.path, got, c.encoded)
+			}
+			decoded, err := decodePath(got)
+			if err != nil || decoded != c.path {
+				t.Errorf("decodePath(%q) = (%q, %v), want %q", got, decoded, err, c.path)
+			}
+		}
+		if needsPathEncoding("a b/c.txt") || !needsPathEncoding("100%") {
+			t.Errorf("needsPathEncoding() is wrong")
+		}
+	})
+
 	// func str2timestamp(str string) (int64, error)
 	// str2timestamp() is called in Tag.String()
 	// func timestamp2string(t int64) string
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StorageClassPrice is the approximate price of a storage class of AWS S3 in us-east-1 (USD)
+type StorageClassPrice struct {
+	Storage   float64 // per GB-month
+	MinDays   int64   // the minimum storage duration. an object deleted or overwritten earlier is charged for the remaining days
+	Retrieval float64 // per GB. archived classes are charged on the restore (restoreTierPrices)
+	Request   float64 // per 1000 PUT/COPY requests to the class
+	Archived  bool    // an object must be restored before it is read
+}
+
+var storageClassPrices = map[string]StorageClassPrice{
+	"STANDARD":            {Storage: 0.023, MinDays: 0, Retrieval: 0, Request: 0.005},
+	"STANDARD_IA":         {Storage: 0.0125, MinDays: 30, Retrieval: 0.01, Request: 0.01},
+	"ONEZONE_IA":          {Storage: 0.01, MinDays: 30, Retrieval: 0.01, Request: 0.01},
+	"INTELLIGENT_TIERING": {Storage: 0.023, MinDays: 0, Retrieval: 0, Request: 0.01},
+	"GLACIER_IR":          {Storage: 0.004, MinDays: 90, Retrieval: 0.03, Request: 0.02},
+	"GLACIER":             {Storage: 0.0036, MinDays: 90, Retrieval: 0, Request: 0.03, Archived: true},
+	"DEEP_ARCHIVE":        {Storage: 0.00099, MinDays: 180, Retrieval: 0, Request: 0.05, Archived: true},
+}
+
+func storageClasses() (classes []string) {
+	for class := range storageClassPrices {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+func findStorageClassPrice(class string) (StorageClassPrice, error) {
+	price, ok := storageClassPrices[class]
+	if !ok {
+		return StorageClassPrice{}, errors.New("The storage class '" + class + "' is unknown. Choose from " + strings.Join(storageClasses(), ", "))
+	}
+	return price, nil
+}
+
+// TransitionBlob is a blob in a bucket to change the storage class
+type TransitionBlob struct {
+	Key    string
+	Object S3Object
+}
+
+// TransitionPlan classifies blobs by what to do to change the storage class
+type TransitionPlan struct {
+	Class     string
+	Copying   []TransitionBlob // copied to the class on the server side
+	Restoring []TransitionBlob // archived. restored first, and copied when the command is excuted again after the restore
+	Waiting   []TransitionBlob // the restore is in progress
+	Transited []TransitionBlob // already in the class
+}
+
+// TransitionCost is the estimated cost of changing storage classes (USD)
+type TransitionCost struct {
+	Requests      float64 // COPY requests and restore requests
+	Retrieval     float64 // retrieval of objects in infrequent access or archived classes
+	Temporary     float64 // restored copies, charged as STANDARD for the valid days
+	Penalty       float64 // objects overwritten before the minimum storage duration
+	MonthlyBefore float64 // the storage per month of the blobs before the transition
+	MonthlyAfter  float64 // the storage per month of the blobs after the transition
+}
+
+func (cost TransitionCost) Total() float64 {
+	return cost.Requests + cost.Retrieval + cost.Temporary + cost.Penalty
+}
+
+func (cost TransitionCost) Strings() []string {
+	return []string{
+		"requests:       " + dollar2string(cost.Requests),
+		"retrieval:      " + dollar2string(cost.Retrieval),
+		"restored copy:  " + dollar2string(cost.Temporary),
+		"early deletion: " + dollar2string(cost.Penalty),
+		"total:          " + dollar2string(cost.Total()),
+		"storage/month:  " + dollar2string(cost.MonthlyBefore) + " -> " + dollar2string(cost.MonthlyAfter),
+	}
+}
+
+func dollar2string(dollar float64) string {
+	return fmt.Sprintf("$%.4f", dollar)
+}
+
+// selectTransitionTags selects tags with unique blobs whose paths match the glob pattern.
+// "**" matches zero or more directories. All tags are selected if the pattern is empty.
+func selectTransitionTags(tags []Tag, pattern string) (selected []Tag) {
+	var hashes []string
+	for _, tag := range blobTags(tags) {
+		if pattern != "" && !matchSegments(strings.Split(pattern, "/"), strings.Split(tag.Path, "/")) {
+			continue
+		}
+		if isIncluded(hashes, tag.Hash.String()) {
+			continue
+		}
+		hashes = append(hashes, tag.Hash.String())
+		selected = append(selected, tag)
+	}
+	return selected
+}
+
+// planTransition looks up the storage class and the restore status of blobs of tags
+func planTransition(location RepositoryLocationS3, tags []Tag, class string) (TransitionPlan, error) {
+	plan := TransitionPlan{Class: class}
+	for _, tag := range tags {
+		key := ".arciv/blob/" + tag.Hash.String()
+		object, err := s3Op.headObject(location.RegionName, location.BucketName, key)
+		if err != nil {
+			return TransitionPlan{}, err
+		}
+		blob := TransitionBlob{Key: key, Object: object}
+		price, err := findStorageClassPrice(object.StorageClass)
+		if err != nil {
+			return TransitionPlan{}, errors.New("The blob " + tag.Hash.String() + ": " + err.Error())
+		}
+		switch {
+		case object.StorageClass == class:
+			plan.Transited = append(plan.Transited, blob)
+		case !price.Archived || object.Restored:
+			plan.Copying = append(plan.Copying, blob)
+		case object.Restoring:
+			plan.Waiting = append(plan.Waiting, blob)
+		default:
+			plan.Restoring = append(plan.Restoring, blob)
+		}
+	}
+	return plan, nil
+}
+
+// estimateTransition estimates the cost of the plan at the unix time now.
+// Archived blobs are counted as if they are copied after the restore.
+func estimateTransition(plan TransitionPlan, validDays int32, now int64) (TransitionCost, error) {
+	to, err := findStorageClassPrice(plan.Class)
+	if err != nil {
+		return TransitionCost{}, err
+	}
+	var cost TransitionCost
+	gb := func(size int64) float64 {
+		return float64(size) / (1024 * 1024 * 1024)
+	}
+	for _, blob := range plan.Transited {
+		cost.MonthlyBefore += gb(blob.Object.Size) * to.Storage
+		cost.MonthlyAfter += gb(blob.Object.Size) * to.Storage
+	}
+	var transiting []TransitionBlob
+	transiting = append(append(append(transiting, plan.Copying...), plan.Restoring...), plan.Waiting...)
+	for _, blob := range transiting {
+		from, err := findStorageClassPrice(blob.Object.StorageClass)
+		if err != nil {
+			return TransitionCost{}, err
+		}
+		size := gb(blob.Object.Size)
+		cost.MonthlyBefore += size * from.Storage
+		cost.MonthlyAfter += size * to.Storage
+		cost.Requests += to.Request / 1000
+		cost.Retrieval += size * from.Retrieval
+		if from.Archived && !blob.Object.Restored && !blob.Object.Restoring {
+			restore, err := findRestoreTierPrice(blob.Object.StorageClass, RESTORE_TIER_BULK)
+			if err != nil {
+				return TransitionCost{}, err
+			}
+			cost.Requests += restore.Request / 1000
+			cost.Retrieval += size * restore.Retrieval
+			cost.Temporary += size * storageClassPrices["STANDARD"].Storage * float64(validDays) / 30
+		}
+		storedDays := (now - blob.Object.LastModified) / (24 * 60 * 60)
+		if storedDays < from.MinDays {
+			cost.Penalty += size * from.Storage * float64(from.MinDays-storedDays) / 30
+		}
+	}
+	return cost, nil
+}
+
+func (plan TransitionPlan) Strings() []string {
+	size := func(blobs []TransitionBlob) string {
+		var total int64
+		for _, blob := range blobs {
+			total += blob.Object.Size
+		}
+		return strconv.Itoa(len(blobs)) + " blobs, " + size2string(total)
+	}
+	return []string{
+		"copy to " + plan.Class + ": " + size(plan.Copying),
+		"restore first:  " + size(plan.Restoring),
+		"restoring:      " + size(plan.Waiting),
+		"already " + plan.Class + ": " + size(plan.Transited),
+	}
+}
+
+// runTransition sends restore requests of archived blobs, and copies other blobs to the storage class.
+// Archived blobs are copied by excuting it again after the restore is completed.
+func runTransition(location RepositoryLocationS3, plan TransitionPlan, validDays int32) error {
+	if len(plan.Restoring) > 0 {
+		var keys []string
+		for _, blob := range plan.Restoring {
+			keys = append(keys, blob.Key)
+		}
+		requested, err := s3Op.receiveBlobsRequest(location.RegionName, location.BucketName, keys, validDays, RESTORE_TIER_BULK)
+		message("sent restore requests of " + strconv.Itoa(len(requested)) + " blobs")
+		if err != nil {
+			return err
+		}
+	}
+	for _, blob := range plan.Copying {
+		err := s3Op.copyObject(location.RegionName, location.BucketName, blob.Key, plan.Class, blob.Object.Size)
+		if err != nil {
+			return err
+		}
+		message("copied to " + plan.Class + ": " + blob.Key[len(".arciv/blob/"):] + ", " + size2string(blob.Object.Size))
+	}
+	if len(plan.Restoring)+len(plan.Waiting) > 0 {
+		message(strconv.Itoa(len(plan.Restoring)+len(plan.Waiting)) + " archived blobs are not copied yet. Excute the command again after the restore is completed (it takes up to 48 hours)")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTransition(t *testing.T) {
+	blob := func(c string) Hash {
+		h, _ := hex2hash(strings.Repeat(c, 64))
+		return h
+	}
+
+	// func selectTransitionTags(tags []Tag, pattern string) (selected []Tag)
+	t.Run("selectTransitionTags()", func(t *testing.T) {
+		tags := []Tag{
+			{Path: "projects/2024/a.txt", Hash: blob("a")},
+			{Path: "projects/2024/sub/b.txt", Hash: blob("b")},
+			{Path: "projects/2024/sub/copy-of-a.txt", Hash: blob("a")},
+			{Path: "projects/2024/link", Type: TAG_TYPE_SYMLINK, Target: "a.txt"},
+			{Path: "projects/2023/c.txt", Hash: blob("c")},
+		}
+		cases := []struct {
+			pattern string
+			want    []string
+		}{
+			{"projects/2024/**", []string{"projects/2024/a.txt", "projects/2024/sub/b.txt"}},
+			{"projects/*/a.txt", []string{"projects/2024/a.txt"}},
+			{"**/c.txt", []string{"projects/2023/c.txt"}},
+			{"", []string{"projects/2024/a.txt", "projects/2024/sub/b.txt", "projects/2023/c.txt"}},
+		}
+		for _, c := range cases {
+			var got []string
+			for _, tag := range selectTransitionTags(tags, c.pattern) {
+				got = append(got, tag.Path)
+			}
+			if strings.Join(got, ",") != strings.Join(c.want, ",") {
+				t.Errorf("selectTransitionTags(%q) = %v, want %v", c.pattern, got, c.want)
+			}
+		}
+	})
+
+	// func planTransition(location RepositoryLocationS3, tags []Tag, class string) (TransitionPlan, error)
+	t.Run("planTransition()", func(t *testing.T) {
+		objects := map[string]S3Object{
+			".arciv/blob/" + blob("a").String(): {StorageClass: "DEEP_ARCHIVE"},
+			".arciv/blob/" + blob("b").String(): {StorageClass: "DEEP_ARCHIVE", Restoring: true},
+			".arciv/blob/" + blob("c").String(): {StorageClass: "DEEP_ARCHIVE", Restored: true},
+			".arciv/blob/" + blob("d").String(): {StorageClass: "STANDARD"},
+			".arciv/blob/" + blob("e").String(): {StorageClass: "GLACIER_IR"},
+		}
+		s3Op = &S3Op{
+			headObject: func(region string, bucket string, name string) (S3Object, error) {
+				if region != "ap-northeast-1" || bucket != "bucket" {
+					t.Errorf("s3Op.headObject() gets invalid region or bucket, %s %s", region, bucket)
+				}
+				return objects[name], nil
+			},
+		}
+		var tags []Tag
+		for _, c := range []string{"a", "b", "c", "d", "e"} {
+			tags = append(tags, Tag{Hash: blob(c)})
+		}
+		plan, err := planTransition(RepositoryLocationS3{RegionName: "ap-northeast-1", BucketName: "bucket"}, tags, "GLACIER_IR")
+		if err != nil {
+			t.Fatalf("planTransition() return error \"%s\", want nil", err)
+		}
+		keys := func(blobs []TransitionBlob) (hashes string) {
+			for _, blob := range blobs {
+				hashes += blob.Key[len(".arciv/blob/"):][:1]
+			}
+			return hashes
+		}
+		if keys(plan.Restoring) != "a" || keys(plan.Waiting) != "b" || keys(plan.Copying) != "cd" || keys(plan.Transited) != "e" {
+			t.Errorf("planTransition() = restoring:%s waiting:%s copying:%s transited:%s, want a b cd e", keys(plan.Restoring), keys(plan.Waiting), keys(plan.Copying), keys(plan.Transited))
+		}
+	})
+
+	// func estimateTransition(plan TransitionPlan, validDays int32, now int64) (TransitionCost, error)
+	t.Run("estimateTransition()", func(t *testing.T) {
+		const day = 24 * 60 * 60
+		const gb = 1024 * 1024 * 1024
+		now := int64(1000 * day)
+		plan := TransitionPlan{
+			Class: "GLACIER_IR",
+			// stored 60 days ago, 120 days are left of the minimum 180 days
+			Restoring: []TransitionBlob{{Object: S3Object{StorageClass: "DEEP_ARCHIVE", Size: 10 * gb, LastModified: now - 60*day}}},
+			// stored long ago, no penalty
+			Copying: []TransitionBlob{{Object: S3Object{StorageClass: "STANDARD", Size: 1 * gb, LastModified: now - 400*day}}},
+		}
+		got, err := estimateTransition(plan, 3, now)
+		if err != nil {
+			t.Fatalf("estimateTransition() return error \"%s\", want nil", err)
+		}
+		want := TransitionCost{
+			Requests:      (0.02 + 0.025 + 0.02) / 1000,
+			Retrieval:     10 * 0.0025,
+			Temporary:     10 * 0.023 * 3 / 30,
+			Penalty:       10 * 0.00099 * 120 / 30,
+			MonthlyBefore: 10*0.00099 + 0.023,
+			MonthlyAfter:  11 * 0.004,
+		}
+		near := func(a, b float64) bool {
+			return math.Abs(a-b) < 1e-9
+		}
+		if !near(got.Requests, want.Requests) || !near(got.Retrieval, want.Retrieval) || !near(got.Temporary, want.Temporary) ||
+			!near(got.Penalty, want.Penalty) || !near(got.MonthlyBefore, want.MonthlyBefore) || !near(got.MonthlyAfter, want.MonthlyAfter) {
+			t.Errorf("estimateTransition() = %+v, want %+v", got, want)
+		}
+
+		_, err = estimateTransition(TransitionPlan{Class: "UNKNOWN"}, 3, now)
+		if err == nil {
+			t.Errorf("estimateTransition() with an unknown class return nil, want error")
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Xattr is an extended attribute of a file.
+// POSIX ACLs are recorded as extended attributes "system.posix_acl_access" and "system.posix_acl_default".
+type Xattr struct {
+	Name  string
+	Value []byte
+}
+
+var errXattrUnsupported = errors.New("Extended attributes are not supported")
+
+func recordingXattrs() bool {
+	return configValue("record-xattrs") == "true"
+}
+
+// Line is a line of .arciv/list/<commit-id>.xattr; the escaped name, the hex value and the path
+func (xattr Xattr) Line(path string) string {
+	return escapeField(xattr.Name) + " " + hex.EncodeToString(xattr.Value) + " " + path
+}
+
+func line2xattr(line string) (path string, xattr Xattr, err error) {
+	elements := strings.SplitN(line, " ", 3)
+	if len(elements) != 3 || elements[2] == "" {
+		return "", Xattr{}, errors.New("A line of an xattr list must be a name, a value and a path separated by a space")
+	}
+	name, err := unescapeField(elements[0])
+	if err != nil {
+		return "", Xattr{}, err
+	}
+	value, err := hex.DecodeString(elements[1])
+	if err != nil {
+		return "", Xattr{}, err
+	}
+	return elements[2], Xattr{Name: name, Value: value}, nil
+}
+
+func (tag Tag) xattrLines() (lines []string) {
+	for _, xattr := range tag.Xattrs {
+		lines = append(lines, xattr.Line(tag.Path))
+	}
+	return lines
+}
+
+func sameXattrs(t0, t1 Tag) bool {
+	return t0.UsedXattrs == t1.UsedXattrs && strings.Join(t0.xattrLines(), "\n") == strings.Join(t1.xattrLines(), "\n")
+}
+
+func sortXattrs(xattrs []Xattr) {
+	sort.Slice(xattrs, func(i, j int) bool {
+		return xattrs[i].Name < xattrs[j].Name
+	})
+}
+
+// xattrsOf reads extended attributes of a file to tag.
+// Unsupported file systems and platforms are reported, and the file is tagged without extended attributes.
+func xattrsOf(path string) ([]Xattr, error) {
+	xattrs, err := fileOp.listXattrs(path)
+	if err == errXattrUnsupported {
+		message("warning: extended attributes of '" + path + "' are not recorded. " + err.Error() + " on the file system or the platform")
+		return []Xattr{}, nil
+	}
+	if err != nil {
+		return []Xattr{}, err
+	}
+	sortXattrs(xattrs)
+	return xattrs, nil
+}
+
+// applyXattrs sets extended attributes of tags.
+// Attributes which cannot be set (ex. unsupported names on the file system) are reported all together.
+func applyXattrs(root string, tags []Tag) error {
+	var failed []string
+	for _, tag := range tags {
+		for _, xattr := range tag.Xattrs {
+			err := fileOp.setXattr(root+"/"+tag.Path, xattr)
+			if err != nil {
+				failed = append(failed, tag.Path+" ("+xattr.Name+"): "+err.Error())
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	for _, f := range failed {
+		message("failed to restore an extended attribute: " + f)
+	}
+	return errors.New("Some extended attributes are not restored")
+}
+
+// usedXattrs returns true if all tags have extended attributes
+func usedXattrs(tags []Tag) bool {
+	for _, tag := range tags {
+		if !tag.UsedXattrs {
+			return false
+		}
+	}
+	return len(tags) > 0
+}
+
+// writeXattrs writes extended attributes of tags written in .arciv/list/<commit-id>
+func (repository Repository) writeXattrs(commitId string, tags []Tag) error {
+	if !usedXattrs(tags) {
+		return nil
+	}
+	lines := []string{"#arciv-xattrs of:" + commitId}
+	encoding := pathEncodingOf(tags)
+	if encoding != "" {
+		lines = append(lines, "#path-encoding:"+encoding)
+	}
+	for _, tag := range tags {
+		if encoding == PATH_ENCODING_PERCENT {
+			tag.Path = encodePath(tag.Path)
+		}
+		lines = append(lines, tag.xattrLines()...)
+	}
+	return repository.Location.writeLines(".arciv/list/"+commitId+".xattr", lines)
+}
+
+// loadXattrs attaches extended attributes recorded in .arciv/list/<commit-id>.xattr to tags loaded from .arciv/list/<commit-id>
+func (repository Repository) loadXattrs(commitId string, tags []Tag) error {
+	lines, err := repository.Location.loadLines(".arciv/list/" + commitId + ".xattr")
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 || lines[0] != "#arciv-xattrs of:"+commitId {
+		return errors.New("The first line of .arciv/list/" + commitId + ".xattr must be '#arciv-xattrs of:" + commitId + "'")
+	}
+	for i := range tags {
+		tags[i].UsedXattrs = true
+	}
+	body := lines[1:]
+	encoded := len(body) > 0 && body[0] == "#path-encoding:"+PATH_ENCODING_PERCENT
+	if encoded {
+		body = body[1:]
+	}
+	for _, line := range body {
+		path, xattr, err := line2xattr(line)
+		if err != nil {
+			return err
+		}
+		if encoded {
+			path, err = decodePath(path)
+			if err != nil {
+				return err
+			}
+		}
+		idx := findTagIndex(tags, Tag{Path: path}, FIND_PATH)
+		if idx == -1 {
+			return errors.New("A path of .arciv/list/" + commitId + ".xattr is not found in the commit")
+		}
+		tags[idx].Xattrs = append(tags[idx].Xattrs, xattr)
+	}
+	return nil
+}
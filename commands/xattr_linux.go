@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+package commands
+
+import (
+	"bytes"
+	"syscall"
+)
+
+func listXattrs(path string) ([]Xattr, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err == syscall.ENOTSUP {
+		return []Xattr{}, errXattrUnsupported
+	}
+	if err != nil || size == 0 {
+		return []Xattr{}, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return []Xattr{}, err
+	}
+	var xattrs []Xattr
+	for _, name := range bytes.Split(bytes.TrimRight(buf[:size], "\x00"), []byte{0}) {
+		value, err := getXattr(path, string(name))
+		if err != nil {
+			return []Xattr{}, err
+		}
+		xattrs = append(xattrs, Xattr{Name: string(name), Value: value})
+	}
+	return xattrs, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil || size == 0 {
+		return []byte{}, err
+	}
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, value)
+	if err != nil {
+		return []byte{}, err
+	}
+	return value[:size], nil
+}
+
+func setXattr(path string, xattr Xattr) error {
+	return syscall.Setxattr(path, xattr.Name, xattr.Value, 0)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package commands
+
+func listXattrs(path string) ([]Xattr, error) {
+	return []Xattr{}, errXattrUnsupported
+}
+
+func setXattr(path string, xattr Xattr) error {
+	return errXattrUnsupported
+}
@@ -6,5 +6,8 @@ require (
 	github.com/aws/aws-sdk-go-v2/config v1.1.4
 	github.com/aws/aws-sdk-go-v2/feature/s3/manager v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.4.0
+	github.com/aws/smithy-go v1.3.0
 	github.com/spf13/cobra v1.1.3
+	golang.org/x/text v0.3.6
+	lukechampine.com/blake3 v1.1.7
 )